@@ -1,18 +1,27 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
 
 	"github.com/mgibula/eve-industry/server"
+	"github.com/mgibula/eve-industry/server/cli"
 	"github.com/mgibula/eve-industry/server/config"
 	"github.com/mgibula/eve-industry/server/db"
 )
 
 func main() {
+	flag.Parse()
+
 	if *config.CookDb != "" {
 		log.Println("Cooking database")
 
 		db.CookDatabase(*config.CookDb)
+	} else if *config.Calculate != "" {
+		if err := cli.Run(*config.Calculate, *config.Format, os.Stdout); err != nil {
+			log.Fatalln(err)
+		}
 	} else {
 		log.Println("Starting EVE Industry Manager")
 
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgibula/eve-industry/server/calculator"
+)
+
+// Versioned JSON API, independent from session and logged in user
+func RegisterRoutes(c *gin.Engine) {
+	v1 := c.Group("/api/v1")
+	v1.POST("/calculate", calculateHandler)
+}
+
+func calculateHandler(c *gin.Context) {
+	type params struct {
+		Blueprints []calculator.BuildRequest `json:"blueprints" binding:"required"`
+	}
+
+	form := params{}
+	if err := c.ShouldBindJSON(&form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := calculator.Calculate(form.Blueprints)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, calculator.NewBuildResponse(result))
+}
@@ -3,6 +3,7 @@ package calculator
 import (
 	"math"
 	"sort"
+	"time"
 
 	"github.com/mgibula/eve-industry/server/db"
 	"gorm.io/gorm"
@@ -10,8 +11,20 @@ import (
 
 type MaterialCalculator struct {
 	EveDB             *gorm.DB
+	Static            *db.StaticData
 	BlueprintSettings map[uint64]BlueprintSettings
 	Materials         map[uint64]*Material
+	Skills            IndustrySkills
+	Prices            map[uint64]float64
+	Stock             map[uint64]int64 // Items already in stockpile, they are not built nor bought
+	Underway          map[uint64]int64 // Runs of jobs already installed, by blueprint
+	HaulingRate       float64          // ISK per m3 of hauled materials and products
+	ReactionFacility  Facility         // Used for reactions planned in structures that can't run them
+	ReactionJobLength time.Duration    // Reactions are split into jobs of at most this length
+
+	adjustedPrices map[uint64]float64
+	costIndices    map[uint64]db.SystemCostIndices
+	materials      []MaterialInfoFull // Result of GetAllMaterials, cleared by Resolve
 }
 
 type Material struct {
@@ -21,10 +34,17 @@ type Material struct {
 	MaterialName         string
 	RequestedQuantity    int64 // Requested by user
 	TotalQuantity        int64 // Total quantity needed
+	extraQuantity        int64 // Added directly, but not requested by user
 	Submaterials         []db.EVEMaterial
+	RequiredSkills       []db.EVEActivitySkill
 	SubmaterialQuantites map[uint64]int64 // Submaterials needed for current item
-	Jobs                 []int64          // Jobs needed, sliced by max runs per bpc
+	Jobs                 []Job            // Jobs needed, sliced by runs of available copies
 	Excess               int64
+	InventionSource      *db.EVEInventionProduct
+	InventionBlueprint   *db.EVEBlueprint
+	InventionMaterials   []db.EVEMaterial
+	InventionSkills      []db.EVEActivitySkill
+	InventionQuantities  map[uint64]int64 // Invention materials needed for all jobs
 }
 
 type MaterialInfo struct {
@@ -40,15 +60,29 @@ type MaterialInfoFull struct {
 	MaterialName        string
 	Quantity            int64
 	Excess              int64
+	Have                int64 // Taken from stockpile
+	Missing             int64 // Still to be built or bought
 	MaterialBlueprintID uint64
 	IsBuilt             bool
+	IsBlueprintCopy     bool // T1 copy consumed by invention, made by copying and not bought
+	GroupName           string
+	CategoryName        string
+	Volume              float64 // Packaged volume of single unit
 
 	MaterialBlueprintName string
 	BuildInfo             struct {
-		Runs int64
-		Jobs []int64
-		ME   int32
-		PE   int32
+		Runs          int64
+		Jobs          []JobInfo
+		TotalTime     time.Duration // Sum of all job times
+		WallClockTime time.Duration // Longest job, when all jobs run in parallel
+		TotalCost     float64
+		MissingCopies int64 // Copies missing from inventory
+		MissingRuns   int64
+		Underway      int64 // Runs already installed, not included in jobs
+		ReactionStage int   // Position in reaction chain, 0 for manufacturing
+		Invention     *InventionInfo
+		ME            int32
+		PE            int32
 	}
 }
 
@@ -57,6 +91,10 @@ type BlueprintSettings struct {
 	ME        int32
 	PE        int32
 	Decryptor uint64
+	Facility  Facility
+	Skills    *IndustrySkills // Skills of character running the jobs, calculator defaults if nil
+	Owned     bool            // Original is owned, so runs are not limited and nothing has to be invented
+	Copies    []BlueprintCopy // Inventory of copies, used before assumed ones
 }
 
 func NewMaterialCalculator() MaterialCalculator {
@@ -64,57 +102,133 @@ func NewMaterialCalculator() MaterialCalculator {
 		BlueprintSettings: make(map[uint64]BlueprintSettings),
 		Materials:         make(map[uint64]*Material),
 		EveDB:             db.OpenEveDatabase(),
+		Static:            db.LoadStaticData(),
+		Skills:            DefaultSkills(),
+		Prices:            make(map[uint64]float64),
+		Stock:             make(map[uint64]int64),
+		Underway:          make(map[uint64]int64),
+		ReactionFacility:  NewReactionFacility(),
+		ReactionJobLength: defaultReactionJobLength,
 	}
 
 	return result
 }
 
-func (c *MaterialCalculator) AddBlueprintSettings(blueprintID uint64, me int32, pe int32, decryptor uint64) {
+func (c *MaterialCalculator) AddBlueprintSettings(blueprintID uint64, me int32, pe int32, decryptor uint64, facility Facility) {
 	blueprint := BlueprintSettings{
 		ME:        me,
 		PE:        pe,
 		Decryptor: decryptor,
+		Facility:  facility,
+	}
+
+	blueprint.Blueprint, _ = c.Static.Blueprint(blueprintID)
+
+	// Formulas can't be researched
+	if blueprint.Blueprint.IsReaction() {
+		blueprint.ME = 0
+		blueprint.PE = 0
+		blueprint.Decryptor = 0
+
+		if !blueprint.Facility.IsRefinery() {
+			blueprint.Facility = c.ReactionFacility
+		}
 	}
 
-	c.EveDB.Where("ID = ?", blueprintID).Take(&blueprint.Blueprint)
 	c.BlueprintSettings[blueprintID] = blueprint
 }
 
+func (c *MaterialCalculator) SetBlueprintSkills(blueprintID uint64, skills IndustrySkills) {
+	if settings, exists := c.BlueprintSettings[blueprintID]; exists {
+		settings.Skills = &skills
+		c.BlueprintSettings[blueprintID] = settings
+	}
+}
+
+func (c *MaterialCalculator) SetOwnedBlueprint(blueprintID uint64) {
+	if settings, exists := c.BlueprintSettings[blueprintID]; exists {
+		settings.Owned = true
+		c.BlueprintSettings[blueprintID] = settings
+	}
+}
+
+func (c *MaterialCalculator) SetBlueprintCopies(blueprintID uint64, copies []BlueprintCopy) {
+	if settings, exists := c.BlueprintSettings[blueprintID]; exists {
+		settings.Copies = copies
+		c.BlueprintSettings[blueprintID] = settings
+	}
+}
+
+// Adds demand for an item. Only demand is recorded, Resolve has to be called after
+// all items are added, so results don't depend on order in which items were added
 func (c *MaterialCalculator) AddQuantity(itemID uint64, name string, quantity int64, is_primary bool) {
-	if _, exists := c.Materials[itemID]; !exists {
-		material := Material{
-			MaterialID:           itemID,
-			MaterialName:         name,
-			SubmaterialQuantites: make(map[uint64]int64),
-			Jobs:                 make([]int64, 0),
-			parent:               c,
-		}
+	material := c.getMaterial(itemID, name)
 
-		var blueprint db.EVEBlueprint
-		err := c.EveDB.Where("manufacturing_product_id = ?", itemID).Take(&blueprint).Error
-		if err == nil {
-			material.BlueprintInfo = &blueprint
-			c.EveDB.Where("blueprint_id = ? and activity_id in (1, 11)", blueprint.ID).Find(&material.Submaterials)
-		}
+	if is_primary {
+		material.RequestedQuantity += quantity
+	} else {
+		material.extraQuantity += quantity
+	}
+}
 
-		c.Materials[itemID] = &material
+func (c *MaterialCalculator) getMaterial(itemID uint64, name string) *Material {
+	if material, exists := c.Materials[itemID]; exists {
+		return material
 	}
 
-	material := c.Materials[itemID]
-	material.addQuantity(quantity, is_primary)
+	material := Material{
+		MaterialID:           itemID,
+		MaterialName:         name,
+		SubmaterialQuantites: make(map[uint64]int64),
+		Jobs:                 make([]Job, 0),
+		InventionQuantities:  make(map[uint64]int64),
+		parent:               c,
+	}
+
+	if blueprint, exists := c.Static.BlueprintForProduct(itemID); exists {
+		material.BlueprintInfo = &blueprint
+		material.Submaterials = c.Static.Materials(blueprint.ID, blueprint.ProductionActivity())
+		material.RequiredSkills = c.Static.Skills(blueprint.ID, blueprint.ProductionActivity())
+		material.loadInvention()
+	}
+
+	c.Materials[itemID] = &material
+	return &material
 }
 
+// Computed once after graph is resolved, every call returns its own copy of the list
 func (c *MaterialCalculator) GetAllMaterials() []MaterialInfoFull {
+	if c.materials == nil {
+		c.materials = c.collectMaterials()
+	}
+
+	return append([]MaterialInfoFull{}, c.materials...)
+}
+
+func (c *MaterialCalculator) collectMaterials() []MaterialInfoFull {
 	result := make([]MaterialInfoFull, 0)
 
 	for _, requiredMaterial := range c.Materials {
+		// Nodes left in graph after demand for them dropped to zero
+		if requiredMaterial.TotalQuantity == 0 {
+			continue
+		}
+
+		itemType, _ := c.Static.TypeInfo(requiredMaterial.MaterialID)
 		info := MaterialInfoFull{
 			MaterialID:   requiredMaterial.MaterialID,
 			MaterialName: requiredMaterial.MaterialName,
 			Quantity:     requiredMaterial.TotalQuantity,
 			Excess:       requiredMaterial.Excess,
+			Have:         requiredMaterial.stockUsed(),
+			GroupName:    itemType.GroupName,
+			CategoryName: itemType.CategoryName,
+			Volume:       itemType.PackagedVolume,
 		}
 
+		info.Missing = info.Quantity - info.Have
+		_, info.IsBlueprintCopy = c.Static.Blueprint(requiredMaterial.MaterialID)
+
 		if requiredMaterial.BlueprintInfo != nil {
 			info.MaterialBlueprintID = requiredMaterial.BlueprintInfo.ID
 			info.MaterialBlueprintName = requiredMaterial.BlueprintInfo.Name
@@ -122,10 +236,29 @@ func (c *MaterialCalculator) GetAllMaterials() []MaterialInfoFull {
 			settings := c.getBlueprintSettings(requiredMaterial.BlueprintInfo.ID)
 			if settings != nil {
 				info.IsBuilt = true
-				info.BuildInfo.Jobs = requiredMaterial.Jobs
 				info.BuildInfo.Runs = requiredMaterial.getTotalRuns()
 				info.BuildInfo.ME = settings.ME
 				info.BuildInfo.PE = settings.PE
+				info.BuildInfo.Invention = requiredMaterial.getInventionInfo(settings)
+				info.BuildInfo.MissingCopies, info.BuildInfo.MissingRuns = requiredMaterial.getShortfall()
+				info.BuildInfo.Underway = requiredMaterial.underwayRuns()
+				info.BuildInfo.ReactionStage = requiredMaterial.reactionStage()
+
+				info.BuildInfo.Jobs = make([]JobInfo, 0, len(requiredMaterial.Jobs))
+				for _, job := range requiredMaterial.Jobs {
+					jobInfo := JobInfo{
+						Job:  job,
+						Time: requiredMaterial.jobTime(job, settings),
+						Cost: requiredMaterial.jobCost(job.Runs, settings),
+					}
+
+					info.BuildInfo.Jobs = append(info.BuildInfo.Jobs, jobInfo)
+					info.BuildInfo.TotalTime += jobInfo.Time
+					info.BuildInfo.TotalCost += jobInfo.Cost
+					if jobInfo.Time > info.BuildInfo.WallClockTime {
+						info.BuildInfo.WallClockTime = jobInfo.Time
+					}
+				}
 			}
 		}
 
@@ -133,7 +266,11 @@ func (c *MaterialCalculator) GetAllMaterials() []MaterialInfoFull {
 	}
 
 	sort.Slice(result, func(i, j int) bool {
-		return result[i].Quantity > result[j].Quantity
+		if result[i].Quantity != result[j].Quantity {
+			return result[i].Quantity > result[j].Quantity
+		}
+
+		return result[i].MaterialID < result[j].MaterialID
 	})
 
 	return result
@@ -162,64 +299,118 @@ func (c *MaterialCalculator) GetMaterialsFor(itemID uint64) []MaterialInfo {
 	return result
 }
 
-func (c *MaterialCalculator) getBlueprintSettings(blueprintID uint64) *BlueprintSettings {
-	if value, exists := c.BlueprintSettings[blueprintID]; exists {
-		return &value
+// Sum of installation costs of all jobs
+func (c *MaterialCalculator) GetTotalJobCost() float64 {
+	var result float64
+
+	for _, material := range c.GetAllMaterials() {
+		result += material.BuildInfo.TotalCost
 	}
 
-	return nil
+	return result
 }
 
-func (c *MaterialCalculator) hasBlueprintSettings(blueprintID uint64) bool {
-	_, exists := c.BlueprintSettings[blueprintID]
-	return exists
-}
+func (c *MaterialCalculator) getAdjustedPrice(itemID uint64) float64 {
+	if c.adjustedPrices == nil {
+		c.adjustedPrices = db.AdjustedPrices(c.EveDB)
+	}
 
-func (material *Material) neededQuantity() int64 {
-	return max(material.TotalQuantity, material.RequestedQuantity)
+	return c.adjustedPrices[itemID]
 }
 
-func (material *Material) neededJobs() []int64 {
-	result := make([]int64, 0)
-	quantityNeeded := material.neededQuantity()
+func (c *MaterialCalculator) getCostIndex(systemID uint64, isReaction bool) float64 {
+	if c.costIndices == nil {
+		c.costIndices = db.CostIndices(c.EveDB)
+	}
 
-	for quantityNeeded > 0 {
-		runsRequired := max(1, int64(math.Ceil(float64(quantityNeeded)/float64(material.BlueprintInfo.ManufacturingProductOutputQuantity))))
-		runsQueued := runsRequired
+	index := c.costIndices[systemID]
 
-		// We assume T1 BPO and max runs BPC for others
-		if !material.BlueprintInfo.IsTech1() {
-			runsQueued = min(runsQueued, material.BlueprintInfo.ManufacturingMaxRuns)
-		}
+	if isReaction {
+		return float64(index.Reaction)
+	}
 
-		quantityNeeded -= runsQueued * material.BlueprintInfo.ManufacturingProductOutputQuantity
-		result = append(result, runsQueued)
+	return float64(index.Manufacturing)
+}
+
+// Estimated item value is based on unmodified blueprint materials
+func (c *MaterialCalculator) estimatedItemValue(materials []db.EVEMaterial, runs int64) float64 {
+	var result float64
+
+	for _, material := range materials {
+		result += float64(material.Quantity*runs) * c.getAdjustedPrice(material.MaterialId)
 	}
 
 	return result
 }
 
-func (material *Material) getRequiredJobs() []int64 {
+func (c *MaterialCalculator) jobCost(blueprint *db.EVEBlueprint, materials []db.EVEMaterial, runs int64, settings *BlueprintSettings) float64 {
+	isReaction := blueprint.IsReaction()
+	costIndex := c.getCostIndex(settings.Facility.SystemID, isReaction)
+
+	return c.estimatedItemValue(materials, runs) * settings.Facility.CostMultiplier(costIndex, isReaction)
+}
+
+func (s *BlueprintSettings) getSkills(c *MaterialCalculator) IndustrySkills {
+	if s.Skills != nil {
+		return *s.Skills
+	}
+
+	return c.Skills
+}
+
+func (c *MaterialCalculator) getBlueprintSettings(blueprintID uint64) *BlueprintSettings {
+	if value, exists := c.BlueprintSettings[blueprintID]; exists {
+		return &value
+	}
+
+	return nil
+}
+
+func (c *MaterialCalculator) hasBlueprintSettings(blueprintID uint64) bool {
+	_, exists := c.BlueprintSettings[blueprintID]
+	return exists
+}
+
+func (material *Material) getRequiredJobs() []Job {
 	return material.Jobs
 }
 
 func (material *Material) getTotalRuns() int64 {
 	var result int64
 
-	for _, runs := range material.Jobs {
-		result += runs
+	for _, job := range material.Jobs {
+		result += job.Runs
 	}
 
 	return result
 }
 
-func (material *Material) addQuantity(quantity int64, is_primary bool) {
-	material.TotalQuantity += quantity
+func (material *Material) jobTime(job Job, settings *BlueprintSettings) time.Duration {
+	return material.jobTimeWithSkills(job, settings, settings.getSkills(material.parent))
+}
 
-	if is_primary {
-		material.RequestedQuantity += quantity
+// Time of the job when run by character with given skills, instead of those set for blueprint
+func (material *Material) jobTimeWithSkills(job Job, settings *BlueprintSettings, skills IndustrySkills) time.Duration {
+	baseTime := material.BlueprintInfo.Manufacturing
+	if material.BlueprintInfo.IsReaction() {
+		baseTime = material.BlueprintInfo.Reaction
 	}
 
+	multiplier := (1.0 - float64(job.PE)*0.01) *
+		settings.Facility.TimeMultiplier(material.BlueprintInfo.IsReaction()) *
+		skills.TimeMultiplier(material.BlueprintInfo, material.RequiredSkills)
+
+	seconds := math.Ceil(float64(baseTime) * float64(job.Runs) * multiplier)
+	return time.Duration(seconds) * time.Second
+}
+
+func (material *Material) jobCost(runs int64, settings *BlueprintSettings) float64 {
+	return material.parent.jobCost(material.BlueprintInfo, material.Submaterials, runs, settings)
+}
+
+// Splits jobs for final quantity and passes material quantities down to inputs.
+// Called once per node, after all its parents
+func (material *Material) resolveJobs() {
 	if material.BlueprintInfo == nil {
 		return
 	}
@@ -229,22 +420,35 @@ func (material *Material) addQuantity(quantity int64, is_primary bool) {
 		return
 	}
 
-	material.Jobs = material.neededJobs()
-	material.Excess = material.getTotalRuns()*material.BlueprintInfo.ManufacturingProductOutputQuantity - material.neededQuantity()
+	material.Jobs = material.neededJobs(settings)
+	material.Excess = (material.getTotalRuns()+material.underwayRuns())*material.BlueprintInfo.ManufacturingProductOutputQuantity - material.neededQuantity()
+
+	facilityMultiplier := settings.Facility.MaterialMultiplier(material.BlueprintInfo.IsReaction())
 
 	for _, submaterial := range material.Submaterials {
-		old_quantity := material.SubmaterialQuantites[submaterial.MaterialId]
-		new_quantity := int64(0)
+		quantity := int64(0)
 
-		for _, runs := range material.Jobs {
-			new_quantity += max(int64(math.Ceil(float64(submaterial.Quantity)*float64(runs)*(1.0-float64(settings.ME)*0.01))), runs)
+		// Every job can use copy with different ME
+		for _, job := range material.Jobs {
+			multiplier := (1.0 - float64(job.ME)*0.01) * facilityMultiplier
+			quantity += materialQuantity(submaterial.Quantity, job.Runs, multiplier)
 		}
 
-		material.SubmaterialQuantites[submaterial.MaterialId] = new_quantity
-		material.parent.AddQuantity(submaterial.MaterialId, submaterial.MaterialName, new_quantity-old_quantity, false)
+		material.SubmaterialQuantites[submaterial.MaterialId] += quantity
+		material.parent.Materials[submaterial.MaterialId].TotalQuantity += quantity
+	}
+
+	if material.isInvented() {
+		material.updateInvention(settings)
 	}
 }
 
+// Same as in-game: quantity is rounded to 2 decimal places before ceiling, and never lower than runs
+func materialQuantity(base int64, runs int64, multiplier float64) int64 {
+	quantity := math.Round(float64(base)*float64(runs)*multiplier*100) / 100
+	return max(int64(math.Ceil(quantity)), runs)
+}
+
 func max(x, y int64) int64 {
 	if x > y {
 		return x
@@ -0,0 +1,509 @@
+package calculator
+
+import (
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mgibula/eve-industry/server/db"
+	"gorm.io/gorm"
+)
+
+const (
+	testTritanium = 34
+	testPyerite   = 35
+	testWidget    = 100
+	testRifter    = 587
+	testSlasher   = 585
+
+	testWidgetBlueprint  = 101
+	testRifterBlueprint  = 691
+	testSlasherBlueprint = 791
+)
+
+// Tests run against a fresh database in temporary directory, static data is loaded from it once
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "eve-industry")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "resources"), 0755); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		log.Fatalln(err)
+	}
+
+	db.InitEveDatabase()
+	seedTestDatabase(db.OpenEveDatabase())
+	seedBenchmarkDatabase(db.OpenEveDatabase())
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Rifter and Slasher share Widget component, built from minerals
+func seedTestDatabase(evedb *gorm.DB) {
+	evedb.Create(&[]db.EVEBlueprint{
+		{ID: testWidgetBlueprint, Name: "Widget Blueprint", Manufacturing: 600, ManufacturingProductId: testWidget, ManufacturingProductName: "Widget", ManufacturingProductOutputQuantity: 1, MetaGroup: db.MetaGroupTech1, ManufacturingMaxRuns: 300},
+		{ID: testRifterBlueprint, Name: "Rifter Blueprint", Manufacturing: 6000, ManufacturingProductId: testRifter, ManufacturingProductName: "Rifter", ManufacturingProductOutputQuantity: 1, MetaGroup: db.MetaGroupTech1, ManufacturingMaxRuns: 100},
+		{ID: testSlasherBlueprint, Name: "Slasher Blueprint", Manufacturing: 6000, ManufacturingProductId: testSlasher, ManufacturingProductName: "Slasher", ManufacturingProductOutputQuantity: 1, MetaGroup: db.MetaGroupTech1, ManufacturingMaxRuns: 100},
+	})
+
+	evedb.Create(&[]db.EVEActivitySkill{
+		{BlueprintId: testWidgetBlueprint, ActivityId: db.ActivityManufacturing, SkillId: SkillIndustry, SkillName: "Industry", Level: 1},
+	})
+
+	evedb.Create(&[]db.EVEMaterial{
+		{BlueprintId: testWidgetBlueprint, ActivityId: db.ActivityManufacturing, MaterialName: "Tritanium", MaterialId: testTritanium, Quantity: 100},
+		{BlueprintId: testWidgetBlueprint, ActivityId: db.ActivityManufacturing, MaterialName: "Pyerite", MaterialId: testPyerite, Quantity: 50},
+		{BlueprintId: testRifterBlueprint, ActivityId: db.ActivityManufacturing, MaterialName: "Tritanium", MaterialId: testTritanium, Quantity: 32000},
+		{BlueprintId: testRifterBlueprint, ActivityId: db.ActivityManufacturing, MaterialName: "Widget", MaterialId: testWidget, Quantity: 10, MaterialBlueprintId: testWidgetBlueprint, MaterialBlueprintOutputQuantity: 1},
+		{BlueprintId: testSlasherBlueprint, ActivityId: db.ActivityManufacturing, MaterialName: "Tritanium", MaterialId: testTritanium, Quantity: 20000},
+		{BlueprintId: testSlasherBlueprint, ActivityId: db.ActivityManufacturing, MaterialName: "Widget", MaterialId: testWidget, Quantity: 7, MaterialBlueprintId: testWidgetBlueprint, MaterialBlueprintOutputQuantity: 1},
+	})
+}
+
+// Synthetic graph sized like a small fleet doctrine: ships built from two tiers of components and minerals
+const (
+	benchmarkMinerals   = 50
+	benchmarkComponents = 40
+	benchmarkShips      = 10
+
+	benchmarkMineral           = 10000
+	benchmarkComponent         = 11000
+	benchmarkComponentPrint    = 12000
+	benchmarkShip              = 13000
+	benchmarkShipBlueprint     = 14000
+	benchmarkComponentsPerShip = 8
+
+	// ESI data of the same size as in production
+	benchmarkAdjustedPrices = 15000
+	benchmarkSystems        = 5000
+	benchmarkSystem         = 30000000
+)
+
+func seedBenchmarkDatabase(evedb *gorm.DB) {
+	blueprints := make([]db.EVEBlueprint, 0)
+	materials := make([]db.EVEMaterial, 0)
+
+	for i := uint64(0); i < benchmarkComponents; i++ {
+		blueprints = append(blueprints, db.EVEBlueprint{ID: benchmarkComponentPrint + i, Name: "Component Blueprint", Manufacturing: 600, ManufacturingProductId: benchmarkComponent + i, ManufacturingProductName: "Component", ManufacturingProductOutputQuantity: 1, MetaGroup: db.MetaGroupTech1, ManufacturingMaxRuns: 300})
+
+		for j := uint64(0); j < 5; j++ {
+			materials = append(materials, db.EVEMaterial{BlueprintId: benchmarkComponentPrint + i, ActivityId: db.ActivityManufacturing, MaterialName: "Mineral", MaterialId: benchmarkMineral + (i*5+j)%benchmarkMinerals, Quantity: int64(10 + j)})
+		}
+
+		// Second tier is built from first tier components
+		if i >= benchmarkComponents/2 {
+			lower := i - benchmarkComponents/2
+			materials = append(materials, db.EVEMaterial{BlueprintId: benchmarkComponentPrint + i, ActivityId: db.ActivityManufacturing, MaterialName: "Component", MaterialId: benchmarkComponent + lower, Quantity: 2, MaterialBlueprintId: benchmarkComponentPrint + lower, MaterialBlueprintOutputQuantity: 1})
+		}
+	}
+
+	for i := uint64(0); i < benchmarkShips; i++ {
+		blueprints = append(blueprints, db.EVEBlueprint{ID: benchmarkShipBlueprint + i, Name: "Ship Blueprint", Manufacturing: 6000, ManufacturingProductId: benchmarkShip + i, ManufacturingProductName: "Ship", ManufacturingProductOutputQuantity: 1, MetaGroup: db.MetaGroupTech1, ManufacturingMaxRuns: 100})
+
+		for j := uint64(0); j < benchmarkComponentsPerShip; j++ {
+			component := (i*3 + j) % benchmarkComponents
+			materials = append(materials, db.EVEMaterial{BlueprintId: benchmarkShipBlueprint + i, ActivityId: db.ActivityManufacturing, MaterialName: "Component", MaterialId: benchmarkComponent + component, Quantity: int64(5 + j), MaterialBlueprintId: benchmarkComponentPrint + component, MaterialBlueprintOutputQuantity: 1})
+		}
+	}
+
+	evedb.Create(&blueprints)
+	evedb.Create(&materials)
+
+	prices := make([]db.AdjustedPrice, 0, benchmarkAdjustedPrices)
+	for i := uint64(0); i < benchmarkAdjustedPrices; i++ {
+		prices = append(prices, db.AdjustedPrice{ID: benchmarkMineral + i, AdjustedPrice: float64(i%1000) + 1})
+	}
+
+	indices := make([]db.SystemCostIndices, 0, benchmarkSystems)
+	for i := uint64(0); i < benchmarkSystems; i++ {
+		indices = append(indices, db.SystemCostIndices{ID: benchmarkSystem + i, Manufacturing: 0.05})
+	}
+
+	evedb.CreateInBatches(&prices, 1000)
+	evedb.CreateInBatches(&indices, 1000)
+}
+
+// Hulls are built in NPC station, Widget with ME 10 in Raitaru
+func newTestCalculator() MaterialCalculator {
+	calculator := NewMaterialCalculator()
+	calculator.AddBlueprintSettings(testRifterBlueprint, 10, 20, 0, NewFacility(int32(StructureStation), int32(RigNone), int32(SecurityHigh)))
+	calculator.AddBlueprintSettings(testSlasherBlueprint, 0, 0, 0, NewFacility(int32(StructureStation), int32(RigNone), int32(SecurityHigh)))
+	calculator.AddBlueprintSettings(testWidgetBlueprint, 10, 20, 0, NewFacility(int32(StructureRaitaru), int32(RigNone), int32(SecurityHigh)))
+
+	return calculator
+}
+
+type testDemand struct {
+	itemID   uint64
+	name     string
+	quantity int64
+}
+
+type testResult struct {
+	Quantity int64
+	Excess   int64
+	Jobs     []Job
+}
+
+func summarize(materials []MaterialInfoFull) map[uint64]testResult {
+	result := make(map[uint64]testResult, len(materials))
+
+	for _, material := range materials {
+		jobs := make([]Job, 0, len(material.BuildInfo.Jobs))
+		for _, job := range material.BuildInfo.Jobs {
+			jobs = append(jobs, job.Job)
+		}
+
+		result[material.MaterialID] = testResult{
+			Quantity: material.Quantity,
+			Excess:   material.Excess,
+			Jobs:     jobs,
+		}
+	}
+
+	return result
+}
+
+func TestMaterialQuantity(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       int64
+		runs       int64
+		multiplier float64
+		expected   int64
+	}{
+		{"no bonuses", 100, 1, 1.0, 100},
+		{"ME 10", 100, 1, 0.9, 90},
+		{"ME 10 in Raitaru", 100, 1, 0.9 * 0.99, 90},
+		{"ME 10 in Raitaru, many runs", 100, 125, 0.9 * 0.99, 11138},
+		{"ME 10 in Raitaru with T1 rig", 1000, 1, 0.9 * 0.99 * 0.98, 874},
+		{"never lower than runs", 1, 10, 0.9, 10},
+		{"rounded to 2 decimals before ceiling", 2, 42, (1.0 - 5*0.01) * 0.99, 79},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := materialQuantity(test.base, test.runs, test.multiplier); result != test.expected {
+				t.Errorf("materialQuantity(%d, %d, %f) = %d, expected %d", test.base, test.runs, test.multiplier, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestFacilityMaterialMultiplier(t *testing.T) {
+	tests := []struct {
+		name       string
+		facility   Facility
+		isReaction bool
+		expected   float64
+	}{
+		{"NPC station", NewFacility(int32(StructureStation), int32(RigTech2), int32(SecurityNull)), false, 1.0},
+		{"Raitaru without rig", NewFacility(int32(StructureRaitaru), int32(RigNone), int32(SecurityHigh)), false, 0.99},
+		{"Raitaru T1 rig highsec", NewFacility(int32(StructureRaitaru), int32(RigTech1), int32(SecurityHigh)), false, 0.99 * (1 - 0.02)},
+		{"Azbel T1 rig lowsec", NewFacility(int32(StructureAzbel), int32(RigTech1), int32(SecurityLow)), false, 0.99 * (1 - 0.038)},
+		{"Sotiyo T2 rig nullsec", NewFacility(int32(StructureSotiyo), int32(RigTech2), int32(SecurityNull)), false, 0.99 * (1 - 0.0504)},
+		{"Athanor manufacturing", NewFacility(int32(StructureAthanor), int32(RigNone), int32(SecurityLow)), false, 1.0},
+		{"Athanor T1 rig lowsec reaction", NewFacility(int32(StructureAthanor), int32(RigTech1), int32(SecurityLow)), true, 1 - 0.02},
+		{"Tatara T2 rig nullsec reaction", NewFacility(int32(StructureTatara), int32(RigTech2), int32(SecurityNull)), true, 1 - 0.0264},
+		{"reaction rig in highsec", NewFacility(int32(StructureAthanor), int32(RigTech2), int32(SecurityHigh)), true, 1.0},
+		{"reaction in engineering complex", NewFacility(int32(StructureRaitaru), int32(RigTech2), int32(SecurityNull)), true, 1.0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.facility.MaterialMultiplier(test.isReaction)
+			if math.Abs(result-test.expected) > 1e-9 {
+				t.Errorf("MaterialMultiplier(%v) = %f, expected %f", test.isReaction, result, test.expected)
+			}
+		})
+	}
+}
+
+// Blueprint ME, structure role bonus and rig bonus are multiplied, then in-game rounding applies
+func TestMaterialBonusStacking(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       int64
+		runs       int64
+		me         int32
+		facility   Facility
+		isReaction bool
+		expected   int64
+	}{
+		{"ME 0 in NPC station", 32000, 10, 0, NewFacility(int32(StructureStation), int32(RigNone), int32(SecurityHigh)), false, 320000},
+		{"ME 10 in NPC station", 32000, 10, 10, NewFacility(int32(StructureStation), int32(RigNone), int32(SecurityHigh)), false, 288000},
+		{"ME 10 in Raitaru T2 rig nullsec", 1000, 10, 10, NewFacility(int32(StructureRaitaru), int32(RigTech2), int32(SecurityNull)), false, 8461},
+		{"ME 10 in Azbel T1 rig lowsec", 500, 1, 10, NewFacility(int32(StructureAzbel), int32(RigTech1), int32(SecurityLow)), false, 429},
+		{"reaction in Tatara T2 rig nullsec", 100, 20, 0, NewFacility(int32(StructureTatara), int32(RigTech2), int32(SecurityNull)), true, 1948},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			multiplier := (1.0 - float64(test.me)*0.01) * test.facility.MaterialMultiplier(test.isReaction)
+			if result := materialQuantity(test.base, test.runs, multiplier); result != test.expected {
+				t.Errorf("quantity = %d, expected %d", result, test.expected)
+			}
+		})
+	}
+}
+
+// Same demand added in different order or in parts gives the same jobs, shared component
+// is split into jobs once for its whole quantity
+func TestJobSplittingOrder(t *testing.T) {
+	expected := map[uint64]testResult{
+		testRifter:    {Quantity: 10, Jobs: []Job{{Runs: 10, ME: 10, PE: 20}}},
+		testSlasher:   {Quantity: 5, Jobs: []Job{{Runs: 5}}},
+		testWidget:    {Quantity: 125, Jobs: []Job{{Runs: 125, ME: 10, PE: 20}}},
+		testTritanium: {Quantity: 288000 + 100000 + 11138, Jobs: []Job{}},
+		testPyerite:   {Quantity: 5569, Jobs: []Job{}},
+	}
+
+	tests := []struct {
+		name    string
+		demands []testDemand
+	}{
+		{"Rifter first", []testDemand{{testRifter, "Rifter", 10}, {testSlasher, "Slasher", 5}}},
+		{"Slasher first", []testDemand{{testSlasher, "Slasher", 5}, {testRifter, "Rifter", 10}}},
+		{"Rifter in parts", []testDemand{{testRifter, "Rifter", 3}, {testSlasher, "Slasher", 5}, {testRifter, "Rifter", 7}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calculator := newTestCalculator()
+			for _, demand := range test.demands {
+				calculator.AddQuantity(demand.itemID, demand.name, demand.quantity, true)
+			}
+			calculator.Resolve()
+
+			if result := summarize(calculator.GetAllMaterials()); !reflect.DeepEqual(result, expected) {
+				t.Errorf("materials = %+v, expected %+v", result, expected)
+			}
+		})
+	}
+}
+
+// Copies from inventory are used best ME first, rest of runs uses copy with its own ME
+func TestJobSplittingCopies(t *testing.T) {
+	calculator := newTestCalculator()
+	calculator.SetBlueprintCopies(testWidgetBlueprint, []BlueprintCopy{
+		{ME: 5, PE: 10, Runs: 50, Quantity: 1},
+		{ME: 10, PE: 20, Runs: 100, Quantity: 1},
+	})
+
+	calculator.AddQuantity(testSlasher, "Slasher", 5, true)
+	calculator.AddQuantity(testRifter, "Rifter", 10, true)
+	calculator.Resolve()
+
+	result := summarize(calculator.GetAllMaterials())
+
+	expectedJobs := []Job{
+		{Runs: 100, ME: 10, PE: 20, Inventory: true},
+		{Runs: 25, ME: 5, PE: 10, Inventory: true},
+	}
+
+	if !reflect.DeepEqual(result[testWidget].Jobs, expectedJobs) {
+		t.Errorf("Widget jobs = %+v, expected %+v", result[testWidget].Jobs, expectedJobs)
+	}
+
+	if expected := int64(288000 + 100000 + 8910 + 2352); result[testTritanium].Quantity != expected {
+		t.Errorf("Tritanium = %d, expected %d", result[testTritanium].Quantity, expected)
+	}
+
+	if expected := int64(4455 + 1176); result[testPyerite].Quantity != expected {
+		t.Errorf("Pyerite = %d, expected %d", result[testPyerite].Quantity, expected)
+	}
+}
+
+// Work done for one calculator page: graph is built and resolved once, materials are read by several cards
+func BenchmarkCalculatorPage(b *testing.B) {
+	facility := NewFacility(int32(StructureRaitaru), int32(RigNone), int32(SecurityHigh))
+	facility.SystemID = benchmarkSystem
+
+	for n := 0; n < b.N; n++ {
+		calculator := NewMaterialCalculator()
+		for i := uint64(0); i < benchmarkComponents; i++ {
+			calculator.AddBlueprintSettings(benchmarkComponentPrint+i, 10, 20, 0, facility)
+		}
+
+		for i := uint64(0); i < benchmarkShips; i++ {
+			calculator.AddBlueprintSettings(benchmarkShipBlueprint+i, 10, 20, 0, facility)
+			calculator.AddQuantity(benchmarkShip+i, "Ship", 20, true)
+		}
+
+		calculator.Resolve()
+
+		for i := 0; i < 3; i++ {
+			calculator.GetAllMaterials()
+		}
+
+		calculator.GetCostBreakdown()
+	}
+}
+
+func TestCalculateValidation(t *testing.T) {
+	value := func(v int32) *int32 { return &v }
+
+	tests := []struct {
+		name    string
+		request BuildRequest
+		valid   bool
+	}{
+		{"defaults", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: 1}, true},
+		{"component only", BuildRequest{BlueprintID: testWidgetBlueprint}, true},
+		{"limits", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: 1, ME: value(10), PE: value(20)}, true},
+		{"negative runs", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: -1}, false},
+		{"runs over limit", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: maxRequestRuns + 1}, false},
+		{"overflowing runs", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: 1 << 60}, false},
+		{"negative me", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: 1, ME: value(-1)}, false},
+		{"me over 10", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: 1, ME: value(11)}, false},
+		{"negative pe", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: 1, PE: value(-2)}, false},
+		{"pe over 20", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: 1, PE: value(21)}, false},
+		{"unknown decryptor", BuildRequest{BlueprintID: testWidgetBlueprint, Runs: 1, Decryptor: 999}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Calculate([]BuildRequest{test.request})
+			if test.valid && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+
+			if !test.valid && err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestScheduleCharacters(t *testing.T) {
+	calculator := newTestCalculator()
+	calculator.AddQuantity(testWidget, "Widget", 10, true)
+	calculator.Resolve()
+
+	t.Run("no characters", func(t *testing.T) {
+		schedule := calculator.GetSchedule(nil)
+		if len(schedule.Jobs) != 1 || len(schedule.Characters) != 1 {
+			t.Fatalf("got %d jobs for %d characters, want default character", len(schedule.Jobs), len(schedule.Characters))
+		}
+
+		if schedule.Jobs[0].MissingSkills {
+			t.Errorf("default character is missing skills")
+		}
+	})
+
+	t.Run("skill not trained", func(t *testing.T) {
+		untrained := IndustrySkills{Levels: map[uint64]int32{}}
+
+		schedule := calculator.GetSchedule([]SchedulerCharacter{
+			{ID: 1, Name: "Untrained", Skills: untrained, Slots: JobSlots{Manufacturing: 1}},
+		})
+
+		if len(schedule.Jobs) != 1 || !schedule.Jobs[0].MissingSkills {
+			t.Errorf("expected job with missing skills, got %+v", schedule.Jobs)
+		}
+	})
+
+	t.Run("duration from picked character", func(t *testing.T) {
+		slow := DefaultSkills()
+		slow.Industry, slow.AdvancedIndustry = 0, 0
+
+		schedule := calculator.GetSchedule([]SchedulerCharacter{
+			{ID: 1, Name: "Slow", Skills: slow, Slots: JobSlots{Manufacturing: 1}},
+			{ID: 2, Name: "Fast", Skills: DefaultSkills(), Slots: JobSlots{Manufacturing: 1}},
+		})
+
+		if len(schedule.Jobs) != 1 {
+			t.Fatalf("got %d jobs, want 1", len(schedule.Jobs))
+		}
+
+		job := schedule.Jobs[0]
+		material := calculator.Materials[testWidget]
+		settings := calculator.getBlueprintSettings(testWidgetBlueprint)
+		expected := material.jobTimeWithSkills(material.Jobs[0], settings, DefaultSkills())
+
+		if job.CharacterID != 2 || job.End-job.Start != expected {
+			t.Errorf("got character %d for %s, want 2 for %s", job.CharacterID, job.End-job.Start, expected)
+		}
+	})
+}
+
+func TestLeafMaterials(t *testing.T) {
+	materials := []MaterialInfoFull{
+		{MaterialID: testTritanium, MaterialName: "Tritanium", Missing: 100},
+		{MaterialID: testPyerite, MaterialName: "Pyerite"},
+		{MaterialID: testWidget, MaterialName: "Widget", Missing: 10, IsBuilt: true},
+		{MaterialID: testRifterBlueprint, MaterialName: "Rifter Blueprint", Missing: 3, IsBlueprintCopy: true},
+	}
+
+	if text := ExportMultibuyText(materials); text != "Tritanium 100\n" {
+		t.Errorf("got multibuy %q", text)
+	}
+}
+
+func TestParseItemList(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []ImportedItem
+	}{
+		{"empty", "", []ImportedItem{}},
+		{"fit header", "[Rifter, Tackle]", []ImportedItem{{"Rifter", 1}}},
+		{"empty slots skipped", "[Rifter, Tackle]\n[Empty Low slot]\n[Empty High slot]", []ImportedItem{{"Rifter", 1}}},
+		{"module with charge", "200mm AutoCannon I, EMP S", []ImportedItem{{"200mm AutoCannon I", 1}}},
+		{"offline module", "Warp Disruptor I /OFFLINE", []ImportedItem{{"Warp Disruptor I", 1}}},
+		{"quantity suffix", "Hobgoblin I x5\nEMP S x1000", []ImportedItem{{"Hobgoblin I", 5}, {"EMP S", 1000}}},
+		{"same items merged", "Damage Control I\n[Empty Low slot]\nDamage Control I\nHobgoblin I x2\nHobgoblin I x3", []ImportedItem{{"Damage Control I", 2}, {"Hobgoblin I", 5}}},
+		{"tab paste", "Tritanium\t1000\nPyerite\t250", []ImportedItem{{"Tritanium", 1000}, {"Pyerite", 250}}},
+		{"tab paste with comma separator", "Tritanium\t1,000\tMineral", []ImportedItem{{"Tritanium", 1000}}},
+		{"tab paste with non-breaking space separator", "Tritanium\t1\u00a0000\tMineral", []ImportedItem{{"Tritanium", 1000}}},
+		{"tab paste without quantity", "Rifter\t\tFrigate", []ImportedItem{{"Rifter", 1}}},
+		{"whitespace trimmed", "  Rifter  \r\n\n", []ImportedItem{{"Rifter", 1}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := ParseItemList(test.text); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("ParseItemList(%q) = %v, expected %v", test.text, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int64
+		valid    bool
+	}{
+		{"plain", "1000", 1000, true},
+		{"comma separators", "1,234,567", 1234567, true},
+		{"dot separators", "1.234.567", 1234567, true},
+		{"space separators", "1 234", 1234, true},
+		{"non-breaking space separators", "1\u00a0234", 1234, true},
+		{"apostrophe separators", "1'234", 1234, true},
+		{"surrounding whitespace", " 42 ", 42, true},
+		{"empty", "", 0, false},
+		{"text", "Mineral", 0, false},
+		{"unit suffix", "100 m3", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, valid := parseQuantity(test.text)
+			if valid != test.valid || (valid && result != test.expected) {
+				t.Errorf("parseQuantity(%q) = %d, %t, expected %d, %t", test.text, result, valid, test.expected, test.valid)
+			}
+		})
+	}
+}
@@ -2,12 +2,18 @@ package calculator
 
 import (
 	"encoding/gob"
+	"log"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mgibula/eve-industry/server/db"
+	"github.com/mgibula/eve-industry/server/esi"
 	"github.com/mgibula/eve-industry/server/layout"
+	"github.com/mgibula/eve-industry/server/locations"
 	"github.com/mgibula/eve-industry/server/sessions"
+	"gorm.io/gorm"
 )
 
 type productionPlan struct {
@@ -17,6 +23,16 @@ type productionPlan struct {
 	PE        int32
 	Selected  bool
 	Decryptor uint64
+	Facility  Facility
+
+	// Filled from blueprint library, copies can be also entered by hand
+	Owned  bool
+	Copies []BlueprintCopy
+
+	// Character chosen to run the jobs
+	CharacterID   uint64
+	CharacterName string
+	MissingSkills []string
 
 	// Filled for templates
 	TotalQuantity  int64
@@ -24,14 +40,30 @@ type productionPlan struct {
 	AdditionalRuns int64
 	Buildable      int64
 	Built          int64
-	Jobs           []int64
+	PlannedJobs    []JobInfo
+	TotalTime      time.Duration
+	WallClockTime  time.Duration
+	TotalCost      float64
+	Invention      *InventionInfo
+	MissingCopies  int64
+	MissingRuns    int64
+	Underway       int64
+	ReactionStage  int
 }
 
 type productionPlans struct {
-	Plans []*productionPlan
+	Plans     []*productionPlan
+	Stockpile uint // Location stock is taken from, 0 if none
+
+	characters []db.ESIUser // Logged characters, or owner and members of project. Their jobs are counted as underway
+	projectID  uint         // Plans are stored in project instead of session when set
+	readOnly   bool         // Project is only shared for viewing
+
+	skills map[uint64]*IndustrySkills // Loaded once per request, nil for characters without imported skills
 }
 
-func (l *productionPlans) addBlueprint(blueprint db.EVEBlueprint, runs int64, me int32, pe int32, selected bool) {
+// Best owned blueprint ME/PE and owned copies are used if available, blueprint defaults otherwise
+func (l *productionPlans) addBlueprint(blueprint db.EVEBlueprint, runs int64, owned []db.OwnedBlueprint, selected bool) {
 	exists := false
 
 	for _, existing := range l.Plans {
@@ -41,13 +73,21 @@ func (l *productionPlans) addBlueprint(blueprint db.EVEBlueprint, runs int64, me
 	}
 
 	if !exists {
-		l.Plans = append(l.Plans, &productionPlan{
+		plan := &productionPlan{
 			Blueprint: blueprint,
 			Runs:      runs,
-			ME:        me,
-			PE:        pe,
+			ME:        blueprint.GetDefaultME(),
+			PE:        blueprint.GetDefaultPE(),
 			Selected:  false,
-		})
+		}
+
+		if blueprint.IsReaction() {
+			plan.Facility = NewReactionFacility()
+		}
+
+		plan.loadOwnedBlueprints(owned)
+
+		l.Plans = append(l.Plans, plan)
 	}
 
 	if selected {
@@ -55,6 +95,17 @@ func (l *productionPlans) addBlueprint(blueprint db.EVEBlueprint, runs int64, me
 	}
 }
 
+func (p *productionPlan) loadOwnedBlueprints(owned []db.OwnedBlueprint) {
+	p.Owned = false
+	p.Copies = CopiesFromLibrary(owned)
+
+	if len(owned) > 0 {
+		p.ME = owned[0].ME
+		p.PE = owned[0].TE
+		p.Owned = !owned[0].IsCopy
+	}
+}
+
 func (l *productionPlans) removeBlueprint(blueprintID uint64) {
 	newPlans := make([]*productionPlan, 0)
 
@@ -93,14 +144,7 @@ func (l *productionPlans) getSelectedPlan() *productionPlan {
 }
 
 func (l *productionPlans) purgeSecondaryBlueprints() {
-	calculator := NewMaterialCalculator()
-	for _, plan := range l.Plans {
-		calculator.AddBlueprintSettings(plan.Blueprint.ID, plan.ME, plan.PE, plan.Decryptor)
-	}
-
-	for _, plan := range l.Plans {
-		calculator.AddQuantity(plan.Blueprint.ManufacturingProductId, plan.Blueprint.ManufacturingProductName, plan.Blueprint.ManufacturingProductOutputQuantity*plan.Runs, true)
-	}
+	calculator := l.newCalculator()
 
 	newPlans := make([]*productionPlan, 0)
 
@@ -115,13 +159,172 @@ func (l *productionPlans) purgeSecondaryBlueprints() {
 	l.Plans = newPlans
 }
 
+// Skills of the character loaded on first use, second value is false when character has no skills imported
+func (l *productionPlans) characterSkills(characterID uint64) (IndustrySkills, bool) {
+	if l.skills == nil {
+		l.skills = make(map[uint64]*IndustrySkills)
+	}
+
+	cached, exists := l.skills[characterID]
+	if !exists {
+		if skills, loaded := LoadCharacterSkills(db.OpenEveDatabase(), characterID); loaded {
+			cached = &skills
+		}
+
+		l.skills[characterID] = cached
+	}
+
+	if cached == nil {
+		return IndustrySkills{}, false
+	}
+
+	return *cached, true
+}
+
+// Picks first character able to run jobs of each plan, characters without imported skills are skipped.
+// Project plans keep character saved with project, unless reassign is set
+func (l *productionPlans) assignCharacters(characters []db.ESIUser, reassign bool) {
+	static := db.LoadStaticData()
+	assign := reassign || l.projectID == 0
+
+	for _, plan := range l.Plans {
+		plan.MissingSkills = nil
+		if !assign && plan.CharacterID > 0 {
+			continue
+		}
+
+		plan.CharacterID = 0
+		plan.CharacterName = ""
+
+		required := append([]db.EVEActivitySkill{}, static.Skills(plan.Blueprint.ID, plan.Blueprint.ProductionActivity())...)
+
+		if invention, exists := static.InventionFor(plan.Blueprint.ID); exists {
+			required = append(required, static.Skills(invention.BlueprintId, db.ActivityInvention)...)
+		}
+
+		for _, character := range characters {
+			skills, loaded := l.characterSkills(character.ID)
+			if !loaded {
+				continue
+			}
+
+			missing := skills.MissingSkills(required)
+			if len(missing) == 0 {
+				// Project plan without character is assigned on next save
+				if assign {
+					plan.CharacterID = character.ID
+					plan.CharacterName = character.CharacterName
+				} else {
+					plan.CharacterName = ""
+				}
+
+				plan.MissingSkills = nil
+				break
+			}
+
+			// Report missing skills of the first character checked
+			if plan.MissingSkills == nil {
+				plan.CharacterName = character.CharacterName
+				plan.MissingSkills = missing
+			}
+		}
+	}
+}
+
+// Characters are assigned again for every calculation, so handlers only rendering plans don't need to save them
+func (l *productionPlans) newCalculator() MaterialCalculator {
+	l.assignCharacters(l.characters, false)
+
+	calculator := NewMaterialCalculator()
+	if l.Stockpile > 0 {
+		calculator.LoadStock(l.Stockpile)
+	}
+
+	if len(l.characters) > 0 {
+		characterIDs := make([]uint64, 0, len(l.characters))
+		corporationIDs := make([]uint64, 0, len(l.characters))
+		for _, character := range l.characters {
+			characterIDs = append(characterIDs, character.ID)
+			corporationIDs = append(corporationIDs, character.CorporationId)
+		}
+
+		calculator.LoadUnderwayJobs(characterIDs, corporationIDs)
+	}
+
+	for _, plan := range l.Plans {
+		calculator.AddBlueprintSettings(plan.Blueprint.ID, plan.ME, plan.PE, plan.Decryptor, plan.Facility)
+		calculator.SetBlueprintCopies(plan.Blueprint.ID, plan.Copies)
+		if plan.Owned {
+			calculator.SetOwnedBlueprint(plan.Blueprint.ID)
+		}
+
+		if plan.CharacterID > 0 {
+			if skills, loaded := l.characterSkills(plan.CharacterID); loaded {
+				calculator.SetBlueprintSkills(plan.Blueprint.ID, skills)
+			}
+		}
+	}
+
+	for _, plan := range l.Plans {
+		calculator.AddQuantity(plan.Blueprint.ManufacturingProductId, plan.Blueprint.ManufacturingProductName, plan.Blueprint.ManufacturingProductOutputQuantity*plan.Runs, true)
+	}
+
+	calculator.Resolve()
+
+	return calculator
+}
+
 func (l *productionPlans) save(c *gin.Context) {
+	if l.readOnly {
+		return
+	}
+
+	if l.projectID > 0 {
+		l.assignCharacters(l.characters, true)
+
+		err := l.saveProject(db.OpenEveDatabase(), l.projectID)
+		if err != nil {
+			log.Println("Error while saving project", l.projectID, err)
+		}
+
+		return
+	}
+
 	session := sessions.OpenSession(c)
 	session.Set("primary_blueprints", *l)
 	session.Save()
 }
 
+// Only selected plan is updated in project, so rendering doesn't overwrite plans changed by other editors
+func (l *productionPlans) saveSelection(c *gin.Context) {
+	if l.readOnly {
+		return
+	}
+
+	if l.projectID > 0 {
+		var selected uint64
+		if plan := l.getSelectedPlan(); plan != nil {
+			selected = plan.Blueprint.ID
+		}
+
+		err := db.OpenEveDatabase().Model(&db.ProjectPlan{}).Where("project_id = ?", l.projectID).Update("selected", gorm.Expr("blueprint_id = ?", selected)).Error
+		if err != nil {
+			log.Println("Error while saving project", l.projectID, err)
+		}
+
+		return
+	}
+
+	l.save(c)
+}
+
 func getProductionPlans(c *gin.Context) productionPlans {
+	if project, role := getCurrentProject(c); project != nil {
+		plans := loadProject(db.OpenEveDatabase(), *project)
+		plans.readOnly = !canEditProject(role)
+		return plans
+	}
+
 	session := sessions.OpenSession(c)
 
 	maybe_list, exists := session.Get("primary_blueprints").(productionPlans)
@@ -133,29 +336,138 @@ func getProductionPlans(c *gin.Context) productionPlans {
 		session.Save()
 	}
 
+	maybe_list.characters = getCharacters(c)
 	return maybe_list
 }
 
+// Logged characters, current one first
+func getCharacters(c *gin.Context) []db.ESIUser {
+	session := sessions.OpenSession(c)
+
+	result := make([]db.ESIUser, 0)
+	current, exists := session.Get("current_user").(db.ESIUser)
+	if exists {
+		result = append(result, current)
+	}
+
+	available, _ := session.Get("available_users").([]db.ESIUser)
+	for _, character := range available {
+		if character.ID != current.ID {
+			result = append(result, character)
+		}
+	}
+
+	return result
+}
+
+// Blueprints owned by logged character or its corporation, best first
+func getOwnedBlueprints(c *gin.Context, blueprintID uint64) []db.OwnedBlueprint {
+	maybe_user, logged := c.Get("user")
+	if !logged {
+		return nil
+	}
+
+	user := maybe_user.(db.ESIUser)
+	return db.FindOwnedBlueprints(db.OpenEveDatabase(), blueprintID, user.ID, user.CorporationId)
+}
+
+func getLocations(c *gin.Context) []locations.LocationInfo {
+	characters := getCharacters(c)
+
+	characterIDs := make([]uint64, 0, len(characters))
+	for _, character := range characters {
+		characterIDs = append(characterIDs, character.ID)
+	}
+
+	return locations.FindLocations(db.OpenEveDatabase(), characterIDs)
+}
+
+func getMarketHub(c *gin.Context) db.MarketHub {
+	session := sessions.OpenSession(c)
+	evedb := db.OpenEveDatabase()
+
+	var hub db.MarketHub
+	hubID, exists := session.Get("market_hub").(uint64)
+	if !exists || evedb.Take(&hub, hubID).Error != nil {
+		evedb.Order("id").First(&hub)
+	}
+
+	return hub
+}
+
+func getHaulingRate(c *gin.Context) float64 {
+	session := sessions.OpenSession(c)
+
+	rate, _ := session.Get("hauling_rate").(float64)
+	return rate
+}
+
+func getOptimizerConstraints(c *gin.Context) OptimizerConstraints {
+	session := sessions.OpenSession(c)
+
+	constraints, _ := session.Get("optimizer_constraints").(OptimizerConstraints)
+	return constraints
+}
+
+func saveOptimizerConstraints(c *gin.Context, constraints OptimizerConstraints) {
+	session := sessions.OpenSession(c)
+	session.Set("optimizer_constraints", constraints)
+	session.Save()
+}
+
 func RegisterRoutes(c *gin.Engine) {
+	gob.Register(OptimizerConstraints{})
 	gob.Register(productionPlan{})
 	gob.Register([]productionPlan{})
 	gob.Register(productionPlans{})
 	gob.Register([]productionPlans{})
 
 	c.GET("/production/calculator", indexHandler)
+	c.GET("/production/projects", projectsHandler)
+	c.POST("/production/projects/create", createProjectHandler)
+	c.POST("/production/projects/rename", renameProjectHandler)
+	c.POST("/production/projects/clone", cloneProjectHandler)
+	c.POST("/production/projects/archive", archiveProjectHandler)
+	c.POST("/production/projects/delete", deleteProjectHandler)
+	c.POST("/production/projects/share", shareProjectHandler)
+	c.POST("/production/projects/unshare", unshareProjectHandler)
+	c.GET("/production/projects/open/:id", openProjectHandler)
+	c.GET("/production/projects/close", closeProjectHandler)
 	c.GET("/production/list-blueprints", listBlueprintsHandler)
 	c.POST("/production/calculator/add-blueprint", addBlueprintHandler)
+	c.POST("/production/calculator/import", importHandler)
 	c.GET("/production/calculator/render-blueprint-card", renderBlueprintCard)
 	c.POST("/production/calculator/render-blueprint-card", renderBlueprintCard)
 	c.GET("/production/calculator/render-blueprint-list", renderBlueprintList)
 	c.GET("/production/calculator/change-blueprint-settings", changeBlueprintSettingsHandler)
+	c.GET("/production/calculator/change-stockpile", changeStockpileHandler)
+	c.GET("/production/calculator/add-blueprint-copy", addBlueprintCopyHandler)
+	c.GET("/production/calculator/remove-blueprint-copy", removeBlueprintCopyHandler)
+	c.GET("/production/calculator/load-blueprint-copies", loadBlueprintCopiesHandler)
 	c.GET("/production/calculator/add-secondary-blueprint", addSecondaryBlueprintHandler)
 	c.GET("/production/calculator/remove-secondary-blueprint", removeSecondaryBlueprintHandler)
 	c.POST("/production/calculator/remove-blueprint", removeBlueprintHandler)
+	c.GET("/production/calculator/render-cost-breakdown", renderCostBreakdown)
+	c.GET("/production/calculator/render-material-tree", renderMaterialTree)
+	c.GET("/production/calculator/material-tree", materialTreeHandler)
+	c.GET("/production/calculator/render-schedule", renderSchedule)
+	c.GET("/production/calculator/schedule", scheduleHandler)
+	c.GET("/production/calculator/change-format", changeFormatHandler)
+	c.GET("/production/calculator/export", exportHandler)
+	c.GET("/production/calculator/change-market-hub", changeMarketHubHandler)
+	c.GET("/production/calculator/change-hauling-rate", changeHaulingRateHandler)
+	c.GET("/production/calculator/refresh-prices", refreshPricesHandler)
+	c.GET("/production/calculator/optimize", optimizeHandler)
+	c.GET("/production/calculator/change-optimizer-constraints", changeOptimizerConstraintsHandler)
 }
 
 func indexHandler(c *gin.Context) {
-	layout.Render(c, "default/calculator.tmpl", gin.H{})
+	project, role := getCurrentProject(c)
+
+	layout.Render(c, "default/calculator.tmpl", gin.H{
+		"project":  project,
+		"readOnly": project != nil && !canEditProject(role),
+	})
 }
 
 func listBlueprintsHandler(c *gin.Context) {
@@ -175,11 +487,20 @@ func listBlueprintsHandler(c *gin.Context) {
 }
 
 func changeBlueprintSettingsHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
 	type params struct {
-		ME        int32  `form:"me" binding:"-"`
-		PE        int32  `form:"pe" binding:"-"`
-		Runs      int64  `form:"runs" binding:"-"`
-		Decryptor uint64 `form:"decryptor" binding:"-"`
+		ME         int32   `form:"me" binding:"-"`
+		PE         int32   `form:"pe" binding:"-"`
+		Runs       int64   `form:"runs" binding:"-"`
+		Decryptor  uint64  `form:"decryptor" binding:"-"`
+		Structure  int32   `form:"structure" binding:"-"`
+		Rig        int32   `form:"rig" binding:"-"`
+		Security   int32   `form:"security" binding:"-"`
+		SystemName string  `form:"system_name" binding:"-"`
+		Tax        float64 `form:"tax" binding:"-"`
 	}
 
 	var form params
@@ -209,11 +530,39 @@ func changeBlueprintSettingsHandler(c *gin.Context) {
 	selectedPlan.Runs = form.Runs
 	selectedPlan.Decryptor = form.Decryptor
 
+	// Formulas can't be researched and reactions run only in refineries
+	if selectedPlan.Blueprint.IsReaction() {
+		selectedPlan.ME = 0
+		selectedPlan.PE = 0
+		selectedPlan.Decryptor = 0
+
+		if form.Structure != int32(StructureAthanor) && form.Structure != int32(StructureTatara) {
+			form.Structure = int32(StructureAthanor)
+		}
+	}
+
+	selectedPlan.Facility = NewFacility(form.Structure, form.Rig, form.Security)
+	selectedPlan.Facility.Tax = form.Tax
+
+	static := db.LoadStaticData()
+	if len(form.SystemName) > 0 {
+		system, exists := static.SystemByName(form.SystemName)
+		if !exists {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		selectedPlan.Facility.SystemID = system.ID
+		selectedPlan.Facility.SystemName = system.SystemName
+	}
+
+	if selectedPlan.Owned {
+		selectedPlan.Decryptor = 0
+	}
+
 	if selectedPlan.Decryptor > 0 {
-		evedb := db.OpenEveDatabase()
-		var decryptor db.EVEDecryptor
-		err := evedb.Where("id = ?", selectedPlan.Decryptor).Take(&decryptor).Error
-		if err != nil {
+		decryptor, exists := static.Decryptor(selectedPlan.Decryptor)
+		if !exists {
 			c.AbortWithStatus(http.StatusNotFound)
 			return
 		}
@@ -226,7 +575,124 @@ func changeBlueprintSettingsHandler(c *gin.Context) {
 	plans.save(c)
 }
 
+func changeStockpileHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
+	type params struct {
+		Stockpile uint `form:"stockpile" binding:"-"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	plans := getProductionPlans(c)
+	plans.Stockpile = 0
+
+	// Only locations of logged characters can be used
+	if form.Stockpile > 0 {
+		for _, location := range getLocations(c) {
+			if location.ID == form.Stockpile {
+				plans.Stockpile = location.ID
+			}
+		}
+	}
+
+	plans.purgeSecondaryBlueprints()
+	plans.save(c)
+}
+
+func addBlueprintCopyHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
+	type params struct {
+		ME       int32 `form:"me" binding:"-"`
+		PE       int32 `form:"pe" binding:"-"`
+		Runs     int64 `form:"runs" binding:"-"`
+		Quantity int64 `form:"quantity" binding:"-"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	plans := getProductionPlans(c)
+	selectedPlan := plans.getSelectedPlan()
+	if selectedPlan == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if form.Runs <= 0 || form.Quantity <= 0 || form.ME < 0 || form.ME > 10 || form.PE < 0 || form.PE > 20 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	selectedPlan.Copies = append(selectedPlan.Copies, BlueprintCopy{
+		ME:       form.ME,
+		PE:       form.PE,
+		Runs:     form.Runs,
+		Quantity: form.Quantity,
+	})
+
+	plans.purgeSecondaryBlueprints()
+	plans.save(c)
+}
+
+func removeBlueprintCopyHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
+	type params struct {
+		Index int `form:"index" binding:"-"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	plans := getProductionPlans(c)
+	selectedPlan := plans.getSelectedPlan()
+	if selectedPlan == nil || form.Index < 0 || form.Index >= len(selectedPlan.Copies) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	selectedPlan.Copies = append(selectedPlan.Copies[:form.Index], selectedPlan.Copies[form.Index+1:]...)
+
+	plans.purgeSecondaryBlueprints()
+	plans.save(c)
+}
+
+// Replaces copies of selected plan with ones from blueprint library
+func loadBlueprintCopiesHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
+	plans := getProductionPlans(c)
+	selectedPlan := plans.getSelectedPlan()
+	if selectedPlan == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	selectedPlan.loadOwnedBlueprints(getOwnedBlueprints(c, selectedPlan.Blueprint.ID))
+	if selectedPlan.Owned {
+		selectedPlan.Decryptor = 0
+	}
+
+	plans.purgeSecondaryBlueprints()
+	plans.save(c)
+}
+
 func addSecondaryBlueprintHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
 	type params struct {
 		BlueprintID uint64 `form:"blueprint_id"`
 	}
@@ -234,22 +700,24 @@ func addSecondaryBlueprintHandler(c *gin.Context) {
 	var form params
 	c.Bind(&form)
 
-	evedb := db.OpenEveDatabase()
-	var blueprint db.EVEBlueprint
-	err := evedb.Where("id = ?", form.BlueprintID).Take(&blueprint).Error
-	if err != nil {
+	blueprint, exists := db.LoadStaticData().Blueprint(form.BlueprintID)
+	if !exists {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
 
 	plans := getProductionPlans(c)
-	plans.addBlueprint(blueprint, 0, blueprint.GetDefaultME(), blueprint.GetDefaultPE(), false)
+	plans.addBlueprint(blueprint, 0, getOwnedBlueprints(c, blueprint.ID), false)
 	plans.save(c)
 
 	renderBlueprintList(c)
 }
 
 func removeBlueprintHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
 	type params struct {
 		BlueprintID uint64 `form:"blueprint_id"`
 	}
@@ -266,6 +734,10 @@ func removeBlueprintHandler(c *gin.Context) {
 }
 
 func removeSecondaryBlueprintHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
 	type params struct {
 		BlueprintID uint64 `form:"blueprint_id"`
 	}
@@ -282,6 +754,10 @@ func removeSecondaryBlueprintHandler(c *gin.Context) {
 }
 
 func addBlueprintHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
 	type params struct {
 		BlueprintName string `form:"blueprint_name"`
 	}
@@ -289,16 +765,14 @@ func addBlueprintHandler(c *gin.Context) {
 	var form params
 	c.Bind(&form)
 
-	evedb := db.OpenEveDatabase()
-	var blueprint db.EVEBlueprint
-	err := evedb.Where("name = ?", form.BlueprintName).Take(&blueprint).Error
-	if err != nil {
+	blueprint, exists := db.LoadStaticData().BlueprintByName(form.BlueprintName)
+	if !exists {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
 
 	blueprints := getProductionPlans(c)
-	blueprints.addBlueprint(blueprint, 1, blueprint.GetDefaultME(), blueprint.GetDefaultPE(), true)
+	blueprints.addBlueprint(blueprint, 1, getOwnedBlueprints(c, blueprint.ID), true)
 	blueprints.save(c)
 
 	renderBlueprintList(c)
@@ -313,9 +787,8 @@ func renderBlueprintCard(c *gin.Context) {
 	c.Bind(&form)
 
 	plans := getProductionPlans(c)
-	if form.BlueprintID > 0 {
-		plans.markPlanSelected(form.BlueprintID)
-		plans.save(c)
+	if form.BlueprintID > 0 && plans.markPlanSelected(form.BlueprintID) {
+		plans.saveSelection(c)
 	}
 
 	selectedPlan := plans.getSelectedPlan()
@@ -323,28 +796,27 @@ func renderBlueprintCard(c *gin.Context) {
 		return
 	}
 
-	calculator := NewMaterialCalculator()
-	for _, plan := range plans.Plans {
-		calculator.AddBlueprintSettings(plan.Blueprint.ID, plan.ME, plan.PE, plan.Decryptor)
-	}
-
-	for _, plan := range plans.Plans {
-		calculator.AddQuantity(plan.Blueprint.ManufacturingProductId, plan.Blueprint.ManufacturingProductName, plan.Blueprint.ManufacturingProductOutputQuantity*plan.Runs, true)
-	}
+	calculator := plans.newCalculator()
 
 	materials := calculator.GetAllMaterials()
 
 	for _, plan := range plans.Plans {
 		info := getMaterialInfo(plan.Blueprint.ManufacturingProductId, materials)
-		plan.Jobs = info.BuildInfo.Jobs
+		plan.PlannedJobs = info.BuildInfo.Jobs
+		plan.TotalTime = info.BuildInfo.TotalTime
+		plan.WallClockTime = info.BuildInfo.WallClockTime
+		plan.TotalCost = info.BuildInfo.TotalCost
+		plan.Invention = info.BuildInfo.Invention
+		plan.MissingCopies = info.BuildInfo.MissingCopies
+		plan.MissingRuns = info.BuildInfo.MissingRuns
+		plan.Underway = info.BuildInfo.Underway
+		plan.ReactionStage = info.BuildInfo.ReactionStage
 		plan.TotalRuns = info.BuildInfo.Runs
 		plan.AdditionalRuns = info.BuildInfo.Runs - plan.Runs
 		plan.TotalQuantity = info.BuildInfo.Runs * plan.Blueprint.ManufacturingProductOutputQuantity
 	}
 
-	evedb := db.OpenEveDatabase()
-	var decryptors []db.EVEDecryptor
-	evedb.Find(&decryptors)
+	decryptors := db.LoadStaticData().Decryptors()
 
 	type ProductInfo struct {
 		ProductID   uint64
@@ -367,9 +839,17 @@ func renderBlueprintCard(c *gin.Context) {
 		products = append(products, info)
 	}
 
+	structures := StructureOptions
+	if selectedPlan.Blueprint.IsReaction() {
+		structures = ReactionStructureOptions
+	}
+
 	layout.Render(c, "ajax/blueprint-card.tmpl", gin.H{
 		"plan":       selectedPlan,
 		"decryptors": decryptors,
+		"structures": structures,
+		"rigs":       RigOptions,
+		"security":   SecurityOptions,
 		"materials":  calculator.GetMaterialsFor(selectedPlan.Blueprint.ManufacturingProductId),
 		"products":   products,
 		"excess":     calculator.GetAllMaterials(),
@@ -379,14 +859,7 @@ func renderBlueprintCard(c *gin.Context) {
 func renderBlueprintList(c *gin.Context) {
 	plans := getProductionPlans(c)
 
-	calculator := NewMaterialCalculator()
-	for _, plan := range plans.Plans {
-		calculator.AddBlueprintSettings(plan.Blueprint.ID, plan.ME, plan.PE, plan.Decryptor)
-	}
-
-	for _, plan := range plans.Plans {
-		calculator.AddQuantity(plan.Blueprint.ManufacturingProductId, plan.Blueprint.ManufacturingProductName, plan.Blueprint.ManufacturingProductOutputQuantity*plan.Runs, true)
-	}
+	calculator := plans.newCalculator()
 
 	materials := calculator.GetAllMaterials()
 
@@ -394,6 +867,7 @@ func renderBlueprintList(c *gin.Context) {
 		info := getMaterialInfo(plan.Blueprint.ManufacturingProductId, materials)
 		plan.TotalQuantity = info.Quantity
 		plan.TotalRuns = info.BuildInfo.Runs
+		plan.Underway = info.BuildInfo.Underway
 		plan.AdditionalRuns = info.BuildInfo.Runs - plan.Runs
 
 		submaterials := calculator.GetMaterialsFor(plan.Blueprint.ManufacturingProductId)
@@ -405,12 +879,177 @@ func renderBlueprintList(c *gin.Context) {
 		}
 	}
 
+	allMaterials := calculator.GetAllMaterials()
+	reactions := GroupReactionStages(allMaterials)
+	format := getOutputFormat(c)
+	export, _ := exportText(format, allMaterials)
+
 	layout.Render(c, "ajax/blueprint-list.tmpl", gin.H{
-		"production": plans,
-		"materials":  calculator.GetAllMaterials(),
+		"production":   plans,
+		"materials":    allMaterials,
+		"groups":       GroupMaterials(allMaterials),
+		"reactions":    reactions,
+		"reactionTime": ReactionChainTime(reactions),
+		"jobCost":      calculator.GetTotalJobCost(),
+		"locations":    getLocations(c),
+		"stockpile":    plans.Stockpile,
+		"outputFormat": format,
+		"export":       export,
 	})
 }
 
+func renderCostBreakdown(c *gin.Context) {
+	plans := getProductionPlans(c)
+	hub := getMarketHub(c)
+
+	calculator := plans.newCalculator()
+	calculator.LoadMarketPrices(hub.ID)
+	calculator.HaulingRate = getHaulingRate(c)
+
+	evedb := db.OpenEveDatabase()
+	var hubs []db.MarketHub
+	evedb.Order("id").Find(&hubs)
+
+	layout.Render(c, "ajax/cost-breakdown.tmpl", gin.H{
+		"hub":         hub,
+		"hubs":        hubs,
+		"costs":       calculator.GetCostBreakdown(),
+		"logistics":   calculator.GetLogistics(),
+		"constraints": getOptimizerConstraints(c),
+	})
+}
+
+func changeHaulingRateHandler(c *gin.Context) {
+	type params struct {
+		Rate float64 `form:"rate" binding:"-"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	session := sessions.OpenSession(c)
+	session.Set("hauling_rate", math.Max(0, form.Rate))
+	session.Save()
+
+	renderCostBreakdown(c)
+}
+
+func changeMarketHubHandler(c *gin.Context) {
+	type params struct {
+		HubID uint64 `form:"hub_id"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	evedb := db.OpenEveDatabase()
+	var hub db.MarketHub
+	err := evedb.Take(&hub, form.HubID).Error
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	session := sessions.OpenSession(c)
+	session.Set("market_hub", hub.ID)
+	session.Save()
+
+	renderCostBreakdown(c)
+}
+
+func refreshPricesHandler(c *gin.Context) {
+	maybe_user, logged := c.Get("user")
+	if !logged {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	plans := getProductionPlans(c)
+	hub := getMarketHub(c)
+	calculator := plans.newCalculator()
+
+	client := esi.NewESIClient(db.OpenEveDatabase(), maybe_user.(db.ESIUser))
+	err := client.UpdateMarketPrices(hub, calculator.GetPricedItems())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error while fetching market prices", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	renderCostBreakdown(c)
+}
+
+func changeOptimizerConstraintsHandler(c *gin.Context) {
+	type params struct {
+		NoReactions bool   `form:"no_reactions" binding:"-"`
+		Toggle      uint64 `form:"toggle_blueprint_id" binding:"-"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	constraints := getOptimizerConstraints(c)
+	constraints.NoReactions = form.NoReactions
+	if form.Toggle > 0 {
+		constraints.ToggleExcluded(form.Toggle)
+	}
+
+	saveOptimizerConstraints(c, constraints)
+	renderCostBreakdown(c)
+}
+
+func optimizeHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
+	maybe_user, logged := c.Get("user")
+	if !logged {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	plans := getProductionPlans(c)
+	hub := getMarketHub(c)
+	calculator := plans.newCalculator()
+	optimizer := calculator.NewBuildOptimizer(getOptimizerConstraints(c))
+
+	client := esi.NewESIClient(db.OpenEveDatabase(), maybe_user.(db.ESIUser))
+	err := client.UpdateMarketPrices(hub, optimizer.GetItems())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Error while fetching market prices", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	calculator.LoadMarketPrices(hub.ID)
+	selected := optimizer.Optimize()
+
+	// Replace secondary blueprints with optimized ones. Plans of blueprints kept by optimizer
+	// are left untouched, their settings are what the optimizer priced them with
+	kept := make(map[uint64]bool, len(selected))
+	for _, blueprint := range selected {
+		kept[blueprint.ID] = true
+	}
+
+	newPlans := make([]*productionPlan, 0)
+	for _, plan := range plans.Plans {
+		if plan.Runs > 0 || kept[plan.Blueprint.ID] {
+			newPlans = append(newPlans, plan)
+		}
+	}
+
+	plans.Plans = newPlans
+	for _, blueprint := range selected {
+		plans.addBlueprint(blueprint, 0, getOwnedBlueprints(c, blueprint.ID), false)
+	}
+
+	plans.purgeSecondaryBlueprints()
+	plans.save(c)
+
+	renderBlueprintList(c)
+}
+
 func getMaterialInfo(materialID uint64, materials []MaterialInfoFull) MaterialInfoFull {
 	for _, material := range materials {
 		if material.MaterialID == materialID {
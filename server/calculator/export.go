@@ -0,0 +1,180 @@
+package calculator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgibula/eve-industry/server/sessions"
+)
+
+const (
+	ExportFull       = "full"
+	ExportMultibuy   = "multibuy"
+	ExportCSV        = "csv"
+	ExportEVEPraisal = "evepraisal"
+)
+
+// Materials that have to be bought - not built and not taken from stockpile.
+// Blueprint copies for invention are left out, they are made by copying jobs
+func LeafMaterials(materials []MaterialInfoFull) []MaterialInfoFull {
+	result := make([]MaterialInfoFull, 0)
+
+	for _, material := range materials {
+		if !material.IsBuilt && !material.IsBlueprintCopy && material.Missing > 0 {
+			result = append(result, material)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MaterialName < result[j].MaterialName
+	})
+
+	return result
+}
+
+// In-game multibuy paste, "Name Qty" per line
+func ExportMultibuyText(materials []MaterialInfoFull) string {
+	var result bytes.Buffer
+
+	for _, material := range LeafMaterials(materials) {
+		fmt.Fprintf(&result, "%s %d\n", material.MaterialName, material.Missing)
+	}
+
+	return result.String()
+}
+
+// Same layout as items copied from inventory window, tab separated
+func ExportEVEPraisalText(materials []MaterialInfoFull) string {
+	var result bytes.Buffer
+
+	for _, material := range LeafMaterials(materials) {
+		fmt.Fprintf(&result, "%s\t%d\n", material.MaterialName, material.Missing)
+	}
+
+	return result.String()
+}
+
+func ExportCSVText(materials []MaterialInfoFull) string {
+	var result bytes.Buffer
+
+	writer := csv.NewWriter(&result)
+	writer.Write([]string{"type_id", "name", "quantity"})
+
+	for _, material := range LeafMaterials(materials) {
+		writer.Write([]string{strconv.FormatUint(material.MaterialID, 10), material.MaterialName, strconv.FormatInt(material.Missing, 10)})
+	}
+
+	writer.Flush()
+	return result.String()
+}
+
+func exportText(format string, materials []MaterialInfoFull) (string, bool) {
+	switch format {
+	case ExportMultibuy:
+		return ExportMultibuyText(materials), true
+	case ExportCSV:
+		return ExportCSVText(materials), true
+	case ExportEVEPraisal:
+		return ExportEVEPraisalText(materials), true
+	default:
+		return "", false
+	}
+}
+
+func getOutputFormat(c *gin.Context) string {
+	session := sessions.OpenSession(c)
+
+	format, ok := session.Get("output_format").(string)
+	if !ok {
+		return ExportFull
+	}
+
+	return format
+}
+
+func changeFormatHandler(c *gin.Context) {
+	type params struct {
+		Format string `form:"format"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	if _, valid := exportText(form.Format, nil); !valid {
+		form.Format = ExportFull
+	}
+
+	session := sessions.OpenSession(c)
+	session.Set("output_format", form.Format)
+	session.Save()
+
+	c.Status(http.StatusOK)
+}
+
+func exportHandler(c *gin.Context) {
+	type params struct {
+		Format string `form:"format"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	plans := getProductionPlans(c)
+	calculator := plans.newCalculator()
+
+	text, valid := exportText(form.Format, calculator.GetAllMaterials())
+	if !valid {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if form.Format == ExportCSV {
+		c.Header("Content-Disposition", "attachment; filename=materials.csv")
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(text))
+		return
+	}
+
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+}
+
+type MaterialGroup struct {
+	Name      string
+	Materials []MaterialInfoFull
+}
+
+// Materials not built, grouped by item group (minerals, moon materials, components...), groups ordered by name
+func GroupMaterials(materials []MaterialInfoFull) []MaterialGroup {
+	result := make([]MaterialGroup, 0)
+	indices := make(map[string]int)
+
+	for _, material := range materials {
+		if material.IsBuilt {
+			continue
+		}
+
+		name := material.GroupName
+		if len(name) == 0 {
+			name = "Other"
+		}
+
+		index, exists := indices[name]
+		if !exists {
+			index = len(result)
+			indices[name] = index
+			result = append(result, MaterialGroup{Name: name})
+		}
+
+		result[index].Materials = append(result[index].Materials, material)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
@@ -0,0 +1,208 @@
+package calculator
+
+type StructureType int32
+
+const (
+	StructureStation StructureType = iota
+	StructureRaitaru
+	StructureAzbel
+	StructureSotiyo
+	StructureAthanor
+	StructureTatara
+)
+
+type RigTier int32
+
+const (
+	RigNone RigTier = iota
+	RigTech1
+	RigTech2
+)
+
+type SecurityClass int32
+
+const (
+	SecurityHigh SecurityClass = iota
+	SecurityLow
+	SecurityNull
+)
+
+type Facility struct {
+	Structure  StructureType
+	Rig        RigTier
+	Security   SecurityClass
+	SystemID   uint64
+	SystemName string
+	Tax        float64 // Structure owner tax in percents, ignored in NPC stations
+}
+
+const (
+	npcStationTax = 0.0025
+	sccSurcharge  = 0.04
+)
+
+type FacilityOption struct {
+	Value int32
+	Name  string
+}
+
+var StructureOptions = []FacilityOption{
+	{Value: int32(StructureStation), Name: "NPC Station"},
+	{Value: int32(StructureRaitaru), Name: "Raitaru"},
+	{Value: int32(StructureAzbel), Name: "Azbel"},
+	{Value: int32(StructureSotiyo), Name: "Sotiyo"},
+	{Value: int32(StructureAthanor), Name: "Athanor"},
+	{Value: int32(StructureTatara), Name: "Tatara"},
+}
+
+// Reactions can only be run in refineries
+var ReactionStructureOptions = []FacilityOption{
+	{Value: int32(StructureAthanor), Name: "Athanor"},
+	{Value: int32(StructureTatara), Name: "Tatara"},
+}
+
+var RigOptions = []FacilityOption{
+	{Value: int32(RigNone), Name: "No rig"},
+	{Value: int32(RigTech1), Name: "T1 rig"},
+	{Value: int32(RigTech2), Name: "T2 rig"},
+}
+
+var SecurityOptions = []FacilityOption{
+	{Value: int32(SecurityHigh), Name: "Highsec"},
+	{Value: int32(SecurityLow), Name: "Lowsec"},
+	{Value: int32(SecurityNull), Name: "Nullsec / WH"},
+}
+
+func NewFacility(structure int32, rig int32, security int32) Facility {
+	result := Facility{}
+
+	if structure >= int32(StructureStation) && structure <= int32(StructureTatara) {
+		result.Structure = StructureType(structure)
+	}
+
+	// Rigs can't be fitted to NPC stations
+	if rig >= int32(RigNone) && rig <= int32(RigTech2) && result.Structure != StructureStation {
+		result.Rig = RigTier(rig)
+	}
+
+	if security >= int32(SecurityHigh) && security <= int32(SecurityNull) {
+		result.Security = SecurityClass(security)
+	}
+
+	return result
+}
+
+// Reactions are not allowed in highsec, lowsec Athanor is assumed
+func NewReactionFacility() Facility {
+	return Facility{
+		Structure: StructureAthanor,
+		Security:  SecurityLow,
+	}
+}
+
+func (f Facility) IsEngineeringComplex() bool {
+	return f.Structure == StructureRaitaru || f.Structure == StructureAzbel || f.Structure == StructureSotiyo
+}
+
+func (f Facility) IsRefinery() bool {
+	return f.Structure == StructureAthanor || f.Structure == StructureTatara
+}
+
+// Multiplier applied to blueprint material quantities, on top of blueprint ME
+func (f Facility) MaterialMultiplier(isReaction bool) float64 {
+	multiplier := 1.0
+
+	// Engineering complexes have 1% material role bonus for manufacturing
+	if !isReaction && f.IsEngineeringComplex() {
+		multiplier *= 0.99
+	}
+
+	return multiplier * (1.0 - f.rigBonus(0.02, 0.024, isReaction))
+}
+
+func (f Facility) rigBonus(tech1 float64, tech2 float64, isReaction bool) float64 {
+	var bonus float64
+
+	// Reaction rigs fit only refineries
+	if isReaction && !f.IsRefinery() {
+		return 0
+	}
+
+	switch f.Rig {
+	case RigTech1:
+		bonus = tech1
+	case RigTech2:
+		bonus = tech2
+	}
+
+	return bonus * f.securityMultiplier(isReaction)
+}
+
+func (f Facility) securityMultiplier(isReaction bool) float64 {
+	if isReaction {
+		switch f.Security {
+		case SecurityLow:
+			return 1.0
+		case SecurityNull:
+			return 1.1
+		default:
+			return 0.0
+		}
+	}
+
+	switch f.Security {
+	case SecurityLow:
+		return 1.9
+	case SecurityNull:
+		return 2.1
+	default:
+		return 1.0
+	}
+}
+
+// Multiplier applied to blueprint job time, on top of blueprint PE and skills
+func (f Facility) TimeMultiplier(isReaction bool) float64 {
+	multiplier := 1.0
+
+	if isReaction {
+		// Tatara has 25% reaction time role bonus
+		if f.Structure == StructureTatara {
+			multiplier *= 0.75
+		}
+	} else {
+		switch f.Structure {
+		case StructureRaitaru:
+			multiplier *= 0.85
+		case StructureAzbel:
+			multiplier *= 0.80
+		case StructureSotiyo:
+			multiplier *= 0.70
+		}
+	}
+
+	return multiplier * (1.0 - f.rigBonus(0.20, 0.24, isReaction))
+}
+
+// Multiplier applied to estimated item value, giving job installation cost
+func (f Facility) CostMultiplier(costIndex float64, isReaction bool) float64 {
+	roleBonus := 1.0
+
+	// Engineering complexes have job cost role bonus for manufacturing
+	if !isReaction {
+		switch f.Structure {
+		case StructureRaitaru:
+			roleBonus = 0.97
+		case StructureAzbel:
+			roleBonus = 0.96
+		case StructureSotiyo:
+			roleBonus = 0.95
+		}
+	}
+
+	tax := f.Tax * 0.01
+	if f.Structure == StructureStation {
+		tax = npcStationTax
+	}
+
+	return costIndex*roleBonus + tax + sccSurcharge
+}
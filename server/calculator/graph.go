@@ -0,0 +1,116 @@
+package calculator
+
+import (
+	"sort"
+)
+
+type materialInput struct {
+	MaterialID   uint64
+	MaterialName string
+}
+
+// Recalculates all quantities from scratch: demand is collected for each node from
+// all its parents first, then jobs are split once per node
+func (c *MaterialCalculator) Resolve() {
+	c.materials = nil
+	order := c.topologicalOrder()
+
+	for _, material := range c.Materials {
+		material.reset()
+	}
+
+	for _, material := range order {
+		material.resolveJobs()
+	}
+}
+
+func (material *Material) reset() {
+	material.TotalQuantity = material.RequestedQuantity + material.extraQuantity
+	material.Excess = 0
+	material.Jobs = make([]Job, 0)
+	material.SubmaterialQuantites = make(map[uint64]int64)
+	material.InventionQuantities = make(map[uint64]int64)
+}
+
+// Parents come before their inputs, nodes are visited in item ID order so the result is stable
+func (c *MaterialCalculator) topologicalOrder() []*Material {
+	roots := make([]*Material, 0)
+	for _, material := range c.Materials {
+		if material.RequestedQuantity+material.extraQuantity != 0 {
+			roots = append(roots, material)
+		}
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].MaterialID < roots[j].MaterialID
+	})
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := make(map[uint64]int)
+	order := make([]*Material, 0, len(c.Materials))
+
+	var visit func(material *Material)
+	visit = func(material *Material) {
+		state[material.MaterialID] = visiting
+
+		for _, input := range material.inputs() {
+			child := c.getMaterial(input.MaterialID, input.MaterialName)
+
+			// Already visiting means a cycle, it is broken here
+			if state[child.MaterialID] == 0 {
+				visit(child)
+			}
+		}
+
+		state[material.MaterialID] = visited
+		order = append(order, material)
+	}
+
+	for _, root := range roots {
+		if state[root.MaterialID] == 0 {
+			visit(root)
+		}
+	}
+
+	// Post-order has inputs first
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+
+	return order
+}
+
+// Items consumed when material is built, including invention materials
+func (material *Material) inputs() []materialInput {
+	result := make([]materialInput, 0)
+	if material.BlueprintInfo == nil {
+		return result
+	}
+
+	settings := material.parent.getBlueprintSettings(material.BlueprintInfo.ID)
+	if settings == nil {
+		return result
+	}
+
+	for _, submaterial := range material.Submaterials {
+		result = append(result, materialInput{submaterial.MaterialId, submaterial.MaterialName})
+	}
+
+	if material.needsInvention(settings) {
+		for _, submaterial := range material.InventionMaterials {
+			result = append(result, materialInput{submaterial.MaterialId, submaterial.MaterialName})
+		}
+
+		if decryptor := material.parent.getDecryptor(settings.Decryptor); decryptor != nil {
+			result = append(result, materialInput{decryptor.ID, decryptor.Name})
+		}
+
+		result = append(result, materialInput{material.InventionSource.BlueprintId, material.InventionSource.BlueprintName})
+	}
+
+	return result
+}
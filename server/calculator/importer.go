@@ -0,0 +1,159 @@
+package calculator
+
+import (
+	"bufio"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgibula/eve-industry/server/db"
+)
+
+type ImportedItem struct {
+	Name     string
+	Quantity int64
+}
+
+// EFT line with quantity, e.g. "Hobgoblin II x5"
+var eftQuantity = regexp.MustCompile(`^(.+?) x(\d+)$`)
+
+// Parses EFT fitting blocks and tab separated inventory copy/paste.
+// Same items are merged, order of first occurrence is kept
+func ParseItemList(text string) []ImportedItem {
+	result := make([]ImportedItem, 0)
+	indices := make(map[string]int)
+
+	add := func(name string, quantity int64) {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 || quantity <= 0 {
+			return
+		}
+
+		if index, exists := indices[name]; exists {
+			result[index].Quantity += quantity
+			return
+		}
+
+		indices[name] = len(result)
+		result = append(result, ImportedItem{Name: name, Quantity: quantity})
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case len(line) == 0:
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			// Fit header "[Ship, Fit name]", or empty slot marker
+			if ship, _, found := strings.Cut(strings.Trim(line, "[]"), ","); found {
+				add(ship, 1)
+			}
+		case strings.Contains(line, "\t"):
+			fields := strings.Split(line, "\t")
+			quantity := int64(1)
+			if len(fields) > 1 {
+				if parsed, ok := parseQuantity(fields[1]); ok {
+					quantity = parsed
+				}
+			}
+
+			add(fields[0], quantity)
+		default:
+			line = strings.TrimSuffix(line, " /OFFLINE")
+
+			if match := eftQuantity.FindStringSubmatch(line); match != nil {
+				quantity, _ := strconv.ParseInt(match[2], 10, 64)
+				add(match[1], quantity)
+			} else {
+				// Loaded charge is not counted, it comes in separate "Charge xN" line
+				module, _, _ := strings.Cut(line, ",")
+				add(module, 1)
+			}
+		}
+	}
+
+	return result
+}
+
+// Quantity may be formatted with thousands separators
+func parseQuantity(text string) (int64, bool) {
+	cleaned := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+
+		if r == ',' || r == '.' || r == ' ' || r == '\u00a0' || r == '\'' {
+			return -1
+		}
+
+		return 'x'
+	}, strings.TrimSpace(text))
+
+	quantity, err := strconv.ParseInt(cleaned, 10, 64)
+	return quantity, err == nil
+}
+
+type importedBlueprint struct {
+	Blueprint db.EVEBlueprint
+	Runs      int64
+}
+
+// Items are matched with blueprints by product name, items without blueprint are returned as unresolved
+func resolveImportedItems(static *db.StaticData, items []ImportedItem) ([]importedBlueprint, []string) {
+	resolved := make([]importedBlueprint, 0)
+	unresolved := make([]string, 0)
+
+	for _, item := range items {
+		blueprint, exists := static.BlueprintForProductName(item.Name)
+		if !exists || blueprint.ManufacturingProductOutputQuantity <= 0 {
+			unresolved = append(unresolved, item.Name)
+			continue
+		}
+
+		output := blueprint.ManufacturingProductOutputQuantity
+		resolved = append(resolved, importedBlueprint{
+			Blueprint: blueprint,
+			Runs:      (item.Quantity + output - 1) / output,
+		})
+	}
+
+	return resolved, unresolved
+}
+
+func importHandler(c *gin.Context) {
+	if !requireEditable(c) {
+		return
+	}
+
+	type params struct {
+		Text string `form:"text"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	resolved, unresolved := resolveImportedItems(db.LoadStaticData(), ParseItemList(form.Text))
+
+	plans := getProductionPlans(c)
+	for _, item := range resolved {
+		plans.addBlueprint(item.Blueprint, 0, getOwnedBlueprints(c, item.Blueprint.ID), false)
+
+		// Imported runs are added to plans already on the list
+		for _, plan := range plans.Plans {
+			if plan.Blueprint.ID == item.Blueprint.ID {
+				plan.Runs += item.Runs
+			}
+		}
+	}
+
+	plans.save(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"added":      len(resolved),
+		"unresolved": unresolved,
+	})
+}
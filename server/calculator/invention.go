@@ -0,0 +1,185 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mgibula/eve-industry/server/db"
+)
+
+type InventionInfo struct {
+	BlueprintID   uint64 // T1 blueprint copies are invented from
+	BlueprintName string
+	Probability   float64 // Success chance, including skills and decryptor
+	RunsPerCopy   int64   // Runs of every invented copy
+	Copies        int64   // Invented copies needed for all jobs
+	Attempts      int64   // Expected invention attempts
+	CopyingTime   time.Duration
+	InventionTime time.Duration // Single attempt
+	Skills        []db.EVEActivitySkill
+	DecryptorID   uint64
+	DecryptorName string
+	Materials     []MaterialInfo
+}
+
+func (i *InventionInfo) ChancePercent() float64 {
+	return i.Probability * 100
+}
+
+func (c *MaterialCalculator) getDecryptor(decryptorID uint64) *db.EVEDecryptor {
+	decryptor, exists := c.Static.Decryptor(decryptorID)
+	if !exists {
+		return nil
+	}
+
+	return &decryptor
+}
+
+func (material *Material) loadInvention() {
+	if material.BlueprintInfo == nil || !material.BlueprintInfo.IsTech2() {
+		return
+	}
+
+	invention, exists := material.parent.Static.InventionFor(material.BlueprintInfo.ID)
+	if !exists {
+		return
+	}
+
+	blueprint, exists := material.parent.Static.Blueprint(invention.BlueprintId)
+	if !exists {
+		return
+	}
+
+	material.InventionSource = &invention
+	material.InventionBlueprint = &blueprint
+	material.InventionMaterials = material.parent.Static.Materials(invention.BlueprintId, db.ActivityInvention)
+	material.InventionSkills = material.parent.Static.Skills(invention.BlueprintId, db.ActivityInvention)
+}
+
+func (material *Material) isInvented() bool {
+	return material.InventionSource != nil
+}
+
+func (material *Material) needsInvention(settings *BlueprintSettings) bool {
+	return material.isInvented() && !settings.Owned
+}
+
+// Base chance is modified by science skills, encryption skill and decryptor
+func (material *Material) inventionChance(settings *BlueprintSettings) float64 {
+	chance := float64(material.InventionSource.Probability) *
+		settings.getSkills(material.parent).InventionMultiplier(material.InventionSkills)
+
+	if decryptor := material.parent.getDecryptor(settings.Decryptor); decryptor != nil {
+		chance *= float64(decryptor.ProbabilityModifier)
+	}
+
+	return math.Min(chance, 1.0)
+}
+
+func (material *Material) inventedRuns(settings *BlueprintSettings) int64 {
+	runs := material.InventionSource.Runs
+
+	if decryptor := material.parent.getDecryptor(settings.Decryptor); decryptor != nil {
+		runs += int64(decryptor.RunsModifier)
+	}
+
+	return max(1, runs)
+}
+
+func (material *Material) inventionAttempts(settings *BlueprintSettings) int64 {
+	if !material.needsInvention(settings) {
+		return 0
+	}
+
+	copies := material.jobsWithoutInventory()
+	chance := material.inventionChance(settings)
+	if copies == 0 || chance <= 0 {
+		return 0
+	}
+
+	return int64(math.Ceil(float64(copies) / chance))
+}
+
+// Datacores, decryptors and T1 blueprint copies consumed by all invention attempts
+func (material *Material) updateInvention(settings *BlueprintSettings) {
+	attempts := material.inventionAttempts(settings)
+	if attempts == 0 {
+		return
+	}
+
+	for _, submaterial := range material.InventionMaterials {
+		material.InventionQuantities[submaterial.MaterialId] += submaterial.Quantity * attempts
+	}
+
+	if decryptor := material.parent.getDecryptor(settings.Decryptor); decryptor != nil {
+		material.InventionQuantities[decryptor.ID] += attempts
+	}
+
+	material.InventionQuantities[material.InventionSource.BlueprintId] += attempts
+
+	for itemID, quantity := range material.InventionQuantities {
+		material.parent.Materials[itemID].TotalQuantity += quantity
+	}
+}
+
+// Research jobs are sped up by Advanced Industry only
+func researchTime(baseTime float64, skills IndustrySkills) time.Duration {
+	multiplier := 1.0 - float64(skills.AdvancedIndustry)*0.03
+	return time.Duration(math.Ceil(baseTime*multiplier)) * time.Second
+}
+
+func (material *Material) copyingTime(runs int64, skills IndustrySkills) time.Duration {
+	return researchTime(float64(material.InventionBlueprint.Copying)*float64(runs), skills)
+}
+
+// Single attempt
+func (material *Material) inventionTime(skills IndustrySkills) time.Duration {
+	return researchTime(float64(material.InventionBlueprint.Invention), skills)
+}
+
+func (material *Material) getInventionInfo(settings *BlueprintSettings) *InventionInfo {
+	if !material.needsInvention(settings) || material.jobsWithoutInventory() == 0 {
+		return nil
+	}
+
+	result := InventionInfo{
+		BlueprintID:   material.InventionSource.BlueprintId,
+		BlueprintName: material.InventionSource.BlueprintName,
+		Probability:   material.inventionChance(settings),
+		RunsPerCopy:   material.inventedRuns(settings),
+		Copies:        material.jobsWithoutInventory(),
+		Attempts:      material.inventionAttempts(settings),
+		Materials:     make([]MaterialInfo, 0, len(material.InventionQuantities)),
+	}
+
+	// Every attempt needs single run copy
+	skills := settings.getSkills(material.parent)
+	result.CopyingTime = material.copyingTime(result.Attempts, skills)
+	result.InventionTime = material.inventionTime(skills)
+	result.Skills = material.InventionSkills
+
+	if decryptor := material.parent.getDecryptor(settings.Decryptor); decryptor != nil {
+		result.DecryptorID = decryptor.ID
+		result.DecryptorName = decryptor.Name
+	}
+
+	for itemID, quantity := range material.InventionQuantities {
+		info := MaterialInfo{
+			MaterialID: itemID,
+			Quantity:   quantity,
+		}
+
+		if inventionMaterial, exists := material.parent.Materials[itemID]; exists {
+			info.MaterialName = inventionMaterial.MaterialName
+		}
+
+		result.Materials = append(result.Materials, info)
+	}
+
+	sort.Slice(result.Materials, func(i, j int) bool {
+		return result.Materials[i].MaterialName < result.Materials[j].MaterialName
+	})
+
+	return &result
+}
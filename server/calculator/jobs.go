@@ -0,0 +1,173 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mgibula/eve-industry/server/db"
+)
+
+// Blueprint copies available for jobs, from blueprint library or entered by hand
+type BlueprintCopy struct {
+	ME       int32
+	PE       int32
+	Runs     int64
+	Quantity int64
+}
+
+type Job struct {
+	Runs      int64
+	ME        int32
+	PE        int32
+	Inventory bool // Job uses copy from inventory
+	Missing   bool // There is no copy in inventory left for this job
+}
+
+type JobInfo struct {
+	Job
+	Time time.Duration
+	Cost float64
+}
+
+// Groups owned copies with the same ME, PE and runs, originals are skipped
+func CopiesFromLibrary(owned []db.OwnedBlueprint) []BlueprintCopy {
+	result := make([]BlueprintCopy, 0)
+
+	for _, blueprint := range owned {
+		if !blueprint.IsCopy {
+			continue
+		}
+
+		merged := false
+		for i, existing := range result {
+			if existing.ME == blueprint.ME && existing.PE == blueprint.TE && existing.Runs == blueprint.Runs {
+				result[i].Quantity++
+				merged = true
+				break
+			}
+		}
+
+		if !merged {
+			result = append(result, BlueprintCopy{
+				ME:       blueprint.ME,
+				PE:       blueprint.TE,
+				Runs:     blueprint.Runs,
+				Quantity: 1,
+			})
+		}
+	}
+
+	return result
+}
+
+// Copies are used best ME first, then the ones with most runs
+func (s *BlueprintSettings) sortedCopies() []BlueprintCopy {
+	result := make([]BlueprintCopy, 0, len(s.Copies))
+	for _, bpc := range s.Copies {
+		if bpc.Runs > 0 && bpc.Quantity > 0 {
+			result = append(result, bpc)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].ME != result[j].ME {
+			return result[i].ME > result[j].ME
+		}
+
+		if result[i].Runs != result[j].Runs {
+			return result[i].Runs > result[j].Runs
+		}
+
+		return result[i].PE > result[j].PE
+	})
+
+	return result
+}
+
+func (material *Material) runsRequired(quantityNeeded int64) int64 {
+	return max(1, int64(math.Ceil(float64(quantityNeeded)/float64(material.BlueprintInfo.ManufacturingProductOutputQuantity))))
+}
+
+// Installed runs are counted only up to the runs needed
+func (material *Material) underwayRuns() int64 {
+	underway := material.parent.Underway[material.BlueprintInfo.ID]
+	if underway == 0 || material.neededQuantity() <= 0 {
+		return 0
+	}
+
+	return min(underway, material.runsRequired(material.neededQuantity()))
+}
+
+func (material *Material) neededJobs(settings *BlueprintSettings) []Job {
+	result := make([]Job, 0)
+	quantityNeeded := material.neededQuantity() - material.underwayRuns()*material.BlueprintInfo.ManufacturingProductOutputQuantity
+
+	// Copies from inventory are used first, owned original makes them unnecessary
+	if !settings.Owned {
+		for _, bpc := range settings.sortedCopies() {
+			for i := int64(0); i < bpc.Quantity && quantityNeeded > 0; i++ {
+				runsQueued := min(material.runsRequired(quantityNeeded), bpc.Runs)
+
+				quantityNeeded -= runsQueued * material.BlueprintInfo.ManufacturingProductOutputQuantity
+				result = append(result, Job{
+					Runs:      runsQueued,
+					ME:        bpc.ME,
+					PE:        bpc.PE,
+					Inventory: true,
+				})
+			}
+		}
+	}
+
+	for quantityNeeded > 0 {
+		runsQueued := material.runsRequired(quantityNeeded)
+
+		// Reactions are queued in batches. Unless original is owned, we assume T1 BPO,
+		// invented BPC for T2 and max runs BPC for others
+		if material.BlueprintInfo.IsReaction() {
+			runsQueued = min(runsQueued, material.reactionBatchRuns(settings))
+		} else if material.needsInvention(settings) {
+			runsQueued = min(runsQueued, material.inventedRuns(settings))
+		} else if !settings.Owned && !material.BlueprintInfo.IsTech1() {
+			runsQueued = min(runsQueued, material.BlueprintInfo.ManufacturingMaxRuns)
+		}
+
+		quantityNeeded -= runsQueued * material.BlueprintInfo.ManufacturingProductOutputQuantity
+		result = append(result, Job{
+			Runs:    runsQueued,
+			ME:      settings.ME,
+			PE:      settings.PE,
+			Missing: !settings.Owned && len(settings.Copies) > 0,
+		})
+	}
+
+	return result
+}
+
+// Jobs not covered by inventory, for T2 these copies have to be invented
+func (material *Material) jobsWithoutInventory() int64 {
+	var result int64
+
+	for _, job := range material.Jobs {
+		if !job.Inventory {
+			result++
+		}
+	}
+
+	return result
+}
+
+// Copies and runs that are missing from inventory
+func (material *Material) getShortfall() (int64, int64) {
+	var copies, runs int64
+
+	for _, job := range material.Jobs {
+		if job.Missing {
+			copies++
+			runs += job.Runs
+		}
+	}
+
+	return copies, runs
+}
@@ -0,0 +1,47 @@
+package calculator
+
+import (
+	"math"
+)
+
+// Cargo capacity of fitted haulers, in m3
+const (
+	freighterCapacity     = 1000000.0
+	jumpFreighterCapacity = 350000.0
+)
+
+type LogisticsInfo struct {
+	InputVolume        float64 // Purchased materials hauled to build location
+	OutputVolume       float64 // Requested products hauled out
+	FreighterTrips     int64
+	JumpFreighterTrips int64
+	HaulingRate        float64 // ISK per m3
+	HaulingCost        float64
+}
+
+// Volumes are packaged, inputs and outputs are hauled in separate trips
+func (c *MaterialCalculator) GetLogistics() LogisticsInfo {
+	result := LogisticsInfo{
+		HaulingRate: c.HaulingRate,
+	}
+
+	for _, material := range c.GetAllMaterials() {
+		if !material.IsBuilt && material.Missing > 0 {
+			result.InputVolume += float64(material.Missing) * material.Volume
+		}
+
+		if requested := c.Materials[material.MaterialID].RequestedQuantity; requested > 0 {
+			result.OutputVolume += float64(requested) * material.Volume
+		}
+	}
+
+	result.FreighterTrips = trips(result.InputVolume, freighterCapacity) + trips(result.OutputVolume, freighterCapacity)
+	result.JumpFreighterTrips = trips(result.InputVolume, jumpFreighterCapacity) + trips(result.OutputVolume, jumpFreighterCapacity)
+	result.HaulingCost = (result.InputVolume + result.OutputVolume) * c.HaulingRate
+
+	return result
+}
+
+func trips(volume float64, capacity float64) int64 {
+	return int64(math.Ceil(volume / capacity))
+}
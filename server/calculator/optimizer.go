@@ -0,0 +1,212 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mgibula/eve-industry/server/db"
+)
+
+type OptimizerConstraints struct {
+	NoReactions bool
+	Excluded    []uint64 // Blueprints that can't be used, e.g. not owned
+}
+
+func (c OptimizerConstraints) IsExcluded(blueprintID uint64) bool {
+	for _, excluded := range c.Excluded {
+		if excluded == blueprintID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *OptimizerConstraints) ToggleExcluded(blueprintID uint64) {
+	excluded := make([]uint64, 0, len(c.Excluded)+1)
+
+	for _, existing := range c.Excluded {
+		if existing != blueprintID {
+			excluded = append(excluded, existing)
+		}
+	}
+
+	if len(excluded) == len(c.Excluded) {
+		excluded = append(excluded, blueprintID)
+	}
+
+	c.Excluded = excluded
+}
+
+func (c OptimizerConstraints) canBuild(blueprint *db.EVEBlueprint) bool {
+	if c.NoReactions && blueprint.IsReaction() {
+		return false
+	}
+
+	return !c.IsExcluded(blueprint.ID)
+}
+
+type optimizerNode struct {
+	itemID    uint64
+	blueprint *db.EVEBlueprint
+	materials []db.EVEMaterial
+	unitCost  float64
+	build     bool
+	resolved  bool
+}
+
+type BuildOptimizer struct {
+	calculator  *MaterialCalculator
+	constraints OptimizerConstraints
+	nodes       map[uint64]*optimizerNode
+	products    []uint64
+}
+
+// Optimizer walks whole material tree of requested products, not only built part of it
+func (c *MaterialCalculator) NewBuildOptimizer(constraints OptimizerConstraints) BuildOptimizer {
+	result := BuildOptimizer{
+		calculator:  c,
+		constraints: constraints,
+		nodes:       make(map[uint64]*optimizerNode),
+		products:    make([]uint64, 0),
+	}
+
+	for _, material := range c.Materials {
+		if material.RequestedQuantity > 0 {
+			result.products = append(result.products, material.MaterialID)
+			result.walk(material.MaterialID)
+		}
+	}
+
+	sort.Slice(result.products, func(i, j int) bool {
+		return result.products[i] < result.products[j]
+	})
+
+	return result
+}
+
+// All items in material tree, market prices are needed for them
+func (o *BuildOptimizer) GetItems() []uint64 {
+	result := make([]uint64, 0, len(o.nodes))
+
+	for itemID := range o.nodes {
+		result = append(result, itemID)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+
+	return result
+}
+
+// Returns blueprints for intermediates that are cheaper to build than to buy
+func (o *BuildOptimizer) Optimize() []db.EVEBlueprint {
+	result := make([]db.EVEBlueprint, 0)
+	visited := make(map[uint64]bool)
+
+	var collect func(itemID uint64)
+	collect = func(itemID uint64) {
+		node := o.nodes[itemID]
+		for _, material := range node.materials {
+			child := o.nodes[material.MaterialId]
+			if visited[child.itemID] {
+				continue
+			}
+
+			visited[child.itemID] = true
+			o.resolve(child)
+
+			if child.build {
+				result = append(result, *child.blueprint)
+				collect(child.itemID)
+			}
+		}
+	}
+
+	for _, product := range o.products {
+		if o.nodes[product].blueprint != nil {
+			collect(product)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
+
+func (o *BuildOptimizer) walk(itemID uint64) {
+	if _, exists := o.nodes[itemID]; exists {
+		return
+	}
+
+	node := &optimizerNode{
+		itemID: itemID,
+	}
+	o.nodes[itemID] = node
+
+	blueprint, exists := o.calculator.Static.BlueprintForProduct(itemID)
+	if !exists {
+		return
+	}
+
+	node.blueprint = &blueprint
+	node.materials = o.calculator.Static.Materials(blueprint.ID, blueprint.ProductionActivity())
+
+	for _, material := range node.materials {
+		o.walk(material.MaterialId)
+	}
+}
+
+// Chooses cheaper of building and buying, items without market price are always built if possible
+func (o *BuildOptimizer) resolve(node *optimizerNode) float64 {
+	if node.resolved {
+		return node.unitCost
+	}
+
+	node.resolved = true
+	node.unitCost = o.calculator.Prices[node.itemID]
+	if node.unitCost == 0 {
+		node.unitCost = math.Inf(1)
+	}
+
+	if node.blueprint != nil && o.constraints.canBuild(node.blueprint) {
+		buildCost := o.buildCost(node)
+		if buildCost < node.unitCost {
+			node.unitCost = buildCost
+			node.build = true
+		}
+	}
+
+	return node.unitCost
+}
+
+// Unit cost of building single run, using plan settings if blueprint is already in plan
+func (o *BuildOptimizer) buildCost(node *optimizerNode) float64 {
+	settings := o.calculator.getBlueprintSettings(node.blueprint.ID)
+	if settings == nil {
+		settings = &BlueprintSettings{
+			ME: node.blueprint.GetDefaultME(),
+			PE: node.blueprint.GetDefaultPE(),
+		}
+
+		if node.blueprint.IsReaction() {
+			settings.Facility = o.calculator.ReactionFacility
+		}
+	}
+
+	multiplier := (1.0 - float64(settings.ME)*0.01) * settings.Facility.MaterialMultiplier(node.blueprint.IsReaction())
+
+	total := o.calculator.jobCost(node.blueprint, node.materials, 1, settings)
+	for _, material := range node.materials {
+		total += float64(material.Quantity) * multiplier * o.resolve(o.nodes[material.MaterialId])
+	}
+
+	if node.blueprint.ManufacturingProductOutputQuantity == 0 {
+		return math.Inf(1)
+	}
+
+	return total / float64(node.blueprint.ManufacturingProductOutputQuantity)
+}
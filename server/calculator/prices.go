@@ -0,0 +1,194 @@
+package calculator
+
+import (
+	"sort"
+
+	"github.com/mgibula/eve-industry/server/db"
+)
+
+type IntermediateCost struct {
+	MaterialID          uint64
+	MaterialName        string
+	MaterialBlueprintID uint64
+	Quantity            int64
+	IsBuilt             bool
+	BuyPrice            float64 // Unit price on the market
+	BuildCost           float64 // Unit cost when built
+	BuildIsCheaper      bool
+}
+
+type PlanCost struct {
+	ProductID   uint64
+	ProductName string
+	Quantity    int64
+	Cost        float64
+	Revenue     float64
+	Profit      float64
+	Margin      float64 // In percents
+}
+
+type CostBreakdown struct {
+	MaterialCost  float64 // Materials still to be bought, stock taken from stockpile is not counted like in exports and hauling
+	JobCost       float64
+	HaulingCost   float64
+	TotalCost     float64
+	Revenue       float64
+	Profit        float64
+	Margin        float64 // In percents
+	Plans         []PlanCost
+	Intermediates []IntermediateCost
+}
+
+// Loads lowest sell prices from given market hub, used as material buy price
+func (c *MaterialCalculator) LoadMarketPrices(hubID uint64) {
+	var prices []db.MarketPrice
+	c.EveDB.Where("hub_id = ?", hubID).Find(&prices)
+
+	c.Prices = make(map[uint64]float64, len(prices))
+	for _, price := range prices {
+		c.Prices[price.TypeId] = price.Sell
+	}
+}
+
+// All item IDs market prices are needed for, including requested products
+func (c *MaterialCalculator) GetPricedItems() []uint64 {
+	result := make([]uint64, 0, len(c.Materials))
+
+	for itemID := range c.Materials {
+		result = append(result, itemID)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+
+	return result
+}
+
+func (c *MaterialCalculator) GetCostBreakdown() CostBreakdown {
+	result := CostBreakdown{
+		Plans:         make([]PlanCost, 0),
+		Intermediates: make([]IntermediateCost, 0),
+	}
+
+	unitCosts := make(map[uint64]float64)
+
+	for _, material := range c.GetAllMaterials() {
+		if material.IsBuilt {
+			result.JobCost += material.BuildInfo.TotalCost
+		} else {
+			result.MaterialCost += float64(material.Missing) * c.Prices[material.MaterialID]
+		}
+
+		if material.MaterialBlueprintID > 0 {
+			info := IntermediateCost{
+				MaterialID:          material.MaterialID,
+				MaterialName:        material.MaterialName,
+				MaterialBlueprintID: material.MaterialBlueprintID,
+				Quantity:            material.Quantity,
+				IsBuilt:             material.IsBuilt,
+				BuyPrice:            c.Prices[material.MaterialID],
+				BuildCost:           c.unitBuildCost(material.MaterialID, unitCosts),
+			}
+
+			info.BuildIsCheaper = info.BuyPrice == 0 || info.BuildCost < info.BuyPrice
+			result.Intermediates = append(result.Intermediates, info)
+		}
+	}
+
+	for _, material := range c.Materials {
+		if material.RequestedQuantity == 0 {
+			continue
+		}
+
+		plan := PlanCost{
+			ProductID:   material.MaterialID,
+			ProductName: material.MaterialName,
+			Quantity:    material.RequestedQuantity,
+			Cost:        float64(material.RequestedQuantity) * c.unitBuildCost(material.MaterialID, unitCosts),
+			Revenue:     float64(material.RequestedQuantity) * c.Prices[material.MaterialID],
+		}
+
+		plan.Profit = plan.Revenue - plan.Cost
+		if plan.Revenue > 0 {
+			plan.Margin = plan.Profit / plan.Revenue * 100
+		}
+
+		result.Revenue += plan.Revenue
+		result.Plans = append(result.Plans, plan)
+	}
+
+	sort.Slice(result.Plans, func(i, j int) bool {
+		return result.Plans[i].ProductName < result.Plans[j].ProductName
+	})
+
+	result.HaulingCost = c.GetLogistics().HaulingCost
+	result.TotalCost = result.MaterialCost + result.JobCost + result.HaulingCost
+	result.Profit = result.Revenue - result.TotalCost
+	if result.Revenue > 0 {
+		result.Margin = result.Profit / result.Revenue * 100
+	}
+
+	return result
+}
+
+// Unit cost of an item - build cost when it is built, market price otherwise
+func (c *MaterialCalculator) unitCost(itemID uint64, cache map[uint64]float64) float64 {
+	material, exists := c.Materials[itemID]
+	if exists && material.BlueprintInfo != nil && c.hasBlueprintSettings(material.BlueprintInfo.ID) {
+		return c.unitBuildCost(itemID, cache)
+	}
+
+	return c.Prices[itemID]
+}
+
+// Unit cost of building an item. For items not built in current plan, it is estimated
+// from single run with default blueprint ME in NPC station
+func (c *MaterialCalculator) unitBuildCost(itemID uint64, cache map[uint64]float64) float64 {
+	if value, exists := cache[itemID]; exists {
+		return value
+	}
+
+	material, exists := c.Materials[itemID]
+	if !exists || material.BlueprintInfo == nil {
+		return 0
+	}
+
+	var total float64
+	var produced int64
+
+	settings := c.getBlueprintSettings(material.BlueprintInfo.ID)
+	if settings != nil && len(material.Jobs) > 0 {
+		for _, submaterial := range material.Submaterials {
+			total += float64(material.SubmaterialQuantites[submaterial.MaterialId]) * c.unitCost(submaterial.MaterialId, cache)
+		}
+
+		for _, job := range material.Jobs {
+			total += material.jobCost(job.Runs, settings)
+		}
+
+		// Datacores, decryptors and T1 copies of all invention attempts are paid by invented runs
+		for inventionID, quantity := range material.InventionQuantities {
+			total += float64(quantity) * c.unitCost(inventionID, cache)
+		}
+
+		produced = material.getTotalRuns() * material.BlueprintInfo.ManufacturingProductOutputQuantity
+	} else {
+		multiplier := 1.0 - float64(material.BlueprintInfo.GetDefaultME())*0.01
+
+		for _, submaterial := range material.Submaterials {
+			total += float64(submaterial.Quantity) * multiplier * c.unitCost(submaterial.MaterialId, cache)
+		}
+
+		total += material.jobCost(1, &BlueprintSettings{})
+		produced = material.BlueprintInfo.ManufacturingProductOutputQuantity
+	}
+
+	result := 0.0
+	if produced > 0 {
+		result = total / float64(produced)
+	}
+
+	cache[itemID] = result
+	return result
+}
@@ -0,0 +1,634 @@
+package calculator
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgibula/eve-industry/server/db"
+	"github.com/mgibula/eve-industry/server/layout"
+	"github.com/mgibula/eve-industry/server/sessions"
+	"gorm.io/gorm"
+)
+
+type projectOwner struct {
+	Value string
+	Name  string
+}
+
+func (p *productionPlan) toProjectPlan(projectID uint) db.ProjectPlan {
+	return db.ProjectPlan{
+		ProjectId:   projectID,
+		BlueprintId: p.Blueprint.ID,
+		Runs:        p.Runs,
+		ME:          p.ME,
+		PE:          p.PE,
+		Selected:    p.Selected,
+		Decryptor:   p.Decryptor,
+		Structure:   int32(p.Facility.Structure),
+		Rig:         int32(p.Facility.Rig),
+		Security:    int32(p.Facility.Security),
+		SystemId:    p.Facility.SystemID,
+		SystemName:  p.Facility.SystemName,
+		Tax:         p.Facility.Tax,
+		Owned:       p.Owned,
+
+		CharacterId:   p.CharacterID,
+		CharacterName: p.CharacterName,
+	}
+}
+
+func newPlanFromProject(row db.ProjectPlan, blueprint db.EVEBlueprint, copies []db.ProjectBlueprintCopy) *productionPlan {
+	plan := &productionPlan{
+		Blueprint: blueprint,
+		Runs:      row.Runs,
+		ME:        row.ME,
+		PE:        row.PE,
+		Selected:  row.Selected,
+		Decryptor: row.Decryptor,
+		Facility:  NewFacility(row.Structure, row.Rig, row.Security),
+		Owned:     row.Owned,
+
+		CharacterID:   row.CharacterId,
+		CharacterName: row.CharacterName,
+	}
+
+	plan.Facility.SystemID = row.SystemId
+	plan.Facility.SystemName = row.SystemName
+	plan.Facility.Tax = row.Tax
+
+	for _, bpc := range copies {
+		plan.Copies = append(plan.Copies, BlueprintCopy{
+			ME:       bpc.ME,
+			PE:       bpc.PE,
+			Runs:     bpc.Runs,
+			Quantity: bpc.Quantity,
+		})
+	}
+
+	return plan
+}
+
+func loadProject(evedb *gorm.DB, project db.Project) productionPlans {
+	result := productionPlans{
+		Plans:      make([]*productionPlan, 0),
+		Stockpile:  project.Stockpile,
+		characters: projectCharacters(evedb, project),
+		projectID:  project.ID,
+	}
+
+	var rows []db.ProjectPlan
+	evedb.Where("project_id = ?", project.ID).Order("id").Find(&rows)
+
+	for _, row := range rows {
+		blueprint, exists := db.LoadStaticData().Blueprint(row.BlueprintId)
+		if !exists {
+			continue
+		}
+
+		var copies []db.ProjectBlueprintCopy
+		evedb.Where("project_plan_id = ?", row.ID).Order("id").Find(&copies)
+
+		result.Plans = append(result.Plans, newPlanFromProject(row, blueprint, copies))
+	}
+
+	return result
+}
+
+// Owner and members of the project known to the application, owner first. Project is calculated
+// with their skills and jobs, so all viewers see the same results
+func projectCharacters(evedb *gorm.DB, project db.Project) []db.ESIUser {
+	characterIDs := make([]uint64, 0)
+	corporationIDs := make([]uint64, 0)
+
+	owner := project.OwnerId
+	if project.IsCorporation {
+		owner = project.CreatorId
+		corporationIDs = append(corporationIDs, project.OwnerId)
+	} else {
+		characterIDs = append(characterIDs, project.OwnerId)
+	}
+
+	var members []db.ProjectMember
+	evedb.Where("project_id = ?", project.ID).Find(&members)
+
+	for _, member := range members {
+		if member.IsCorporation {
+			corporationIDs = append(corporationIDs, member.MemberId)
+		} else {
+			characterIDs = append(characterIDs, member.MemberId)
+		}
+	}
+
+	var result []db.ESIUser
+	evedb.Where("id in ? or corporation_id in ?", characterIDs, corporationIDs).Order("id").Find(&result)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID == owner && result[j].ID != owner
+	})
+
+	return result
+}
+
+// All plans of the project are replaced
+func (l *productionPlans) saveProject(evedb *gorm.DB, projectID uint) error {
+	return evedb.Transaction(func(tx *gorm.DB) error {
+		var planIDs []uint64
+		tx.Model(&db.ProjectPlan{}).Where("project_id = ?", projectID).Pluck("id", &planIDs)
+
+		if len(planIDs) > 0 {
+			if err := tx.Where("project_plan_id in ?", planIDs).Delete(&db.ProjectBlueprintCopy{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("project_id = ?", projectID).Delete(&db.ProjectPlan{}).Error; err != nil {
+			return err
+		}
+
+		for _, plan := range l.Plans {
+			row := plan.toProjectPlan(projectID)
+			if err := tx.Create(&row).Error; err != nil {
+				return err
+			}
+
+			for _, bpc := range plan.Copies {
+				saved := db.ProjectBlueprintCopy{
+					ProjectPlanId: row.ID,
+					ME:            bpc.ME,
+					PE:            bpc.PE,
+					Runs:          bpc.Runs,
+					Quantity:      bpc.Quantity,
+				}
+
+				if err := tx.Create(&saved).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Model(&db.Project{}).Where("id = ?", projectID).Update("stockpile", l.Stockpile).Error
+	})
+}
+
+func deleteProject(evedb *gorm.DB, project db.Project) error {
+	return evedb.Transaction(func(tx *gorm.DB) error {
+		empty := productionPlans{}
+		if err := empty.saveProject(tx, project.ID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("project_id = ?", project.ID).Delete(&db.ProjectMember{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&project).Error
+	})
+}
+
+func characterAndCorporationIDs(characters []db.ESIUser) ([]uint64, []uint64) {
+	characterIDs := make([]uint64, 0, len(characters))
+	corporationIDs := make([]uint64, 0, len(characters))
+
+	for _, character := range characters {
+		characterIDs = append(characterIDs, character.ID)
+		if character.CorporationId > 0 {
+			corporationIDs = append(corporationIDs, character.CorporationId)
+		}
+	}
+
+	return characterIDs, corporationIDs
+}
+
+// Character creating the project among logged ones, for corporation projects it has to be corporation member
+func projectCreator(project db.Project, characters []db.ESIUser) uint64 {
+	for _, character := range characters {
+		if !project.IsCorporation && project.OwnerId == character.ID {
+			return character.ID
+		}
+
+		if project.IsCorporation && character.CorporationId > 0 && project.OwnerId == character.CorporationId {
+			return character.ID
+		}
+	}
+
+	return 0
+}
+
+// Owner role is given to owning character, or to creator of corporation project. Other members
+// of owning corporation are editors, everyone else needs to be shared with.
+// Highest role of all logged characters is used, empty string means no access
+func projectRole(evedb *gorm.DB, project db.Project, characters []db.ESIUser) string {
+	corporationMember := false
+
+	for _, character := range characters {
+		if !project.IsCorporation && project.OwnerId == character.ID {
+			return db.ProjectRoleOwner
+		}
+
+		if project.IsCorporation && character.CorporationId > 0 && project.OwnerId == character.CorporationId {
+			if project.CreatorId == character.ID {
+				return db.ProjectRoleOwner
+			}
+
+			corporationMember = true
+		}
+	}
+
+	if corporationMember {
+		return db.ProjectRoleEditor
+	}
+
+	characterIDs, corporationIDs := characterAndCorporationIDs(characters)
+
+	var members []db.ProjectMember
+	evedb.Where("project_id = ? and ((member_id in ? and is_corporation = ?) or (member_id in ? and is_corporation = ?))", project.ID, characterIDs, false, corporationIDs, true).Find(&members)
+
+	result := ""
+	for _, member := range members {
+		if member.Role == db.ProjectRoleEditor {
+			return db.ProjectRoleEditor
+		}
+
+		result = db.ProjectRoleViewer
+	}
+
+	return result
+}
+
+func canEditProject(role string) bool {
+	return role == db.ProjectRoleOwner || role == db.ProjectRoleEditor
+}
+
+func getProject(c *gin.Context, projectID uint) (db.Project, string) {
+	evedb := db.OpenEveDatabase()
+
+	var project db.Project
+	if evedb.Take(&project, projectID).Error != nil {
+		return project, ""
+	}
+
+	return project, projectRole(evedb, project, getCharacters(c))
+}
+
+// Project opened in calculator with role of logged characters, nil when plans are kept in session only
+func getCurrentProject(c *gin.Context) (*db.Project, string) {
+	session := sessions.OpenSession(c)
+
+	projectID, exists := session.Get("current_project").(uint)
+	if !exists || projectID == 0 {
+		return nil, ""
+	}
+
+	project, role := getProject(c, projectID)
+	if len(role) == 0 || project.Archived {
+		return nil, ""
+	}
+
+	return &project, role
+}
+
+// Plans of projects that can be only viewed are not saved
+func requireEditable(c *gin.Context) bool {
+	if project, role := getCurrentProject(c); project != nil && !canEditProject(role) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+func getProjectOwners(characters []db.ESIUser) []projectOwner {
+	result := make([]projectOwner, 0)
+
+	for _, character := range characters {
+		result = append(result, projectOwner{
+			Value: fmt.Sprintf("character:%d", character.ID),
+			Name:  character.CharacterName,
+		})
+	}
+
+	for _, character := range characters {
+		if character.CorporationId > 0 {
+			result = append(result, projectOwner{
+				Value: fmt.Sprintf("corporation:%d", character.CorporationId),
+				Name:  character.CharacterName + "'s corporation",
+			})
+		}
+	}
+
+	return result
+}
+
+func projectsHandler(c *gin.Context) {
+	type projectInfo struct {
+		db.Project
+		Role    string
+		Members []db.ProjectMember
+	}
+
+	characters := getCharacters(c)
+	characterIDs, corporationIDs := characterAndCorporationIDs(characters)
+	evedb := db.OpenEveDatabase()
+
+	var current uint
+	if project, _ := getCurrentProject(c); project != nil {
+		current = project.ID
+	}
+
+	projects := make([]projectInfo, 0)
+	for _, project := range db.FindProjects(evedb, characterIDs, corporationIDs) {
+		info := projectInfo{
+			Project: project,
+			Role:    projectRole(evedb, project, characters),
+		}
+
+		evedb.Where("project_id = ?", project.ID).Order("id").Find(&info.Members)
+		projects = append(projects, info)
+	}
+
+	layout.Render(c, "default/projects.tmpl", gin.H{
+		"projects": projects,
+		"owners":   getProjectOwners(characters),
+		"project":  current,
+	})
+}
+
+func createProjectHandler(c *gin.Context) {
+	type params struct {
+		Name        string `form:"name"`
+		Owner       string `form:"owner"`
+		CopyCurrent bool   `form:"copy_current"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	if len(strings.TrimSpace(form.Name)) == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	project := db.Project{
+		Name: strings.TrimSpace(form.Name),
+	}
+
+	ownerType, ownerID, _ := strings.Cut(form.Owner, ":")
+	project.OwnerId, _ = strconv.ParseUint(ownerID, 10, 64)
+	project.IsCorporation = ownerType == "corporation"
+	project.CreatorId = projectCreator(project, getCharacters(c))
+
+	if project.CreatorId == 0 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	evedb := db.OpenEveDatabase()
+	if err := evedb.Create(&project).Error; err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if form.CopyCurrent {
+		plans := getProductionPlans(c)
+		plans.saveProject(evedb, project.ID)
+	}
+
+	openProject(c, project.ID)
+	c.Redirect(http.StatusFound, "/production/calculator")
+}
+
+func renameProjectHandler(c *gin.Context) {
+	type params struct {
+		ProjectID uint   `form:"project_id"`
+		Name      string `form:"name"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	project, role := getProject(c, form.ProjectID)
+	if !canEditProject(role) || len(strings.TrimSpace(form.Name)) == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	db.OpenEveDatabase().Model(&project).Update("name", strings.TrimSpace(form.Name))
+	c.Redirect(http.StatusFound, "/production/projects")
+}
+
+func cloneProjectHandler(c *gin.Context) {
+	type params struct {
+		ProjectID uint `form:"project_id"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	project, role := getProject(c, form.ProjectID)
+	if !canEditProject(role) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	evedb := db.OpenEveDatabase()
+	plans := loadProject(evedb, project)
+
+	clone := db.Project{
+		Name:          project.Name + " (copy)",
+		OwnerId:       project.OwnerId,
+		IsCorporation: project.IsCorporation,
+		Stockpile:     project.Stockpile,
+	}
+
+	// Editor cloning corporation project owns the clone
+	if clone.IsCorporation {
+		clone.CreatorId = projectCreator(clone, getCharacters(c))
+		if clone.CreatorId == 0 {
+			clone.CreatorId = project.CreatorId
+		}
+	}
+
+	// Members are not copied, so editor cloning shared character project becomes its owner
+	if !clone.IsCorporation && role != db.ProjectRoleOwner {
+		maybe_user, logged := c.Get("user")
+		if !logged {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		clone.OwnerId = maybe_user.(db.ESIUser).ID
+	}
+
+	if err := evedb.Create(&clone).Error; err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// Characters saved with plans may not belong to new owner
+	if clone.OwnerId != project.OwnerId {
+		plans.characters = projectCharacters(evedb, clone)
+		plans.assignCharacters(plans.characters, true)
+	}
+
+	plans.saveProject(evedb, clone.ID)
+	c.Redirect(http.StatusFound, "/production/projects")
+}
+
+func archiveProjectHandler(c *gin.Context) {
+	type params struct {
+		ProjectID uint `form:"project_id"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	project, role := getProject(c, form.ProjectID)
+	if role != db.ProjectRoleOwner {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	db.OpenEveDatabase().Model(&project).Update("archived", !project.Archived)
+	c.Redirect(http.StatusFound, "/production/projects")
+}
+
+func deleteProjectHandler(c *gin.Context) {
+	type params struct {
+		ProjectID uint `form:"project_id"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	project, role := getProject(c, form.ProjectID)
+	if role != db.ProjectRoleOwner {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	if err := deleteProject(db.OpenEveDatabase(), project); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/production/projects")
+}
+
+// Member can be given by character or corporation ID. Characters known to the application
+// can be also given by name, corporation of such character is shared with then
+func shareProjectHandler(c *gin.Context) {
+	type params struct {
+		ProjectID  uint   `form:"project_id"`
+		Member     string `form:"member"`
+		MemberType string `form:"member_type"`
+		Role       string `form:"role"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	project, role := getProject(c, form.ProjectID)
+	if role != db.ProjectRoleOwner {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	if form.Role != db.ProjectRoleEditor && form.Role != db.ProjectRoleViewer {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	member := db.ProjectMember{
+		ProjectId:     project.ID,
+		IsCorporation: form.MemberType == "corporation",
+		Role:          form.Role,
+	}
+
+	evedb := db.OpenEveDatabase()
+	name := strings.TrimSpace(form.Member)
+
+	var character db.ESIUser
+	if id, err := strconv.ParseUint(name, 10, 64); err == nil {
+		member.MemberId = id
+		if !member.IsCorporation && evedb.Take(&character, id).Error == nil {
+			member.MemberName = character.CharacterName
+		}
+	} else if evedb.Where("character_name = ?", name).Take(&character).Error == nil {
+		member.MemberId = character.ID
+		member.MemberName = character.CharacterName
+
+		if member.IsCorporation {
+			member.MemberId = character.CorporationId
+			member.MemberName = character.CharacterName + "'s corporation"
+		}
+	}
+
+	if member.MemberId == 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if len(member.MemberName) == 0 {
+		member.MemberName = fmt.Sprint(member.MemberId)
+	}
+
+	evedb.Where("project_id = ? and member_id = ? and is_corporation = ?", project.ID, member.MemberId, member.IsCorporation).Delete(&db.ProjectMember{})
+	evedb.Create(&member)
+
+	c.Redirect(http.StatusFound, "/production/projects")
+}
+
+func unshareProjectHandler(c *gin.Context) {
+	type params struct {
+		ProjectID uint   `form:"project_id"`
+		MemberID  uint64 `form:"member_id"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	project, role := getProject(c, form.ProjectID)
+	if role != db.ProjectRoleOwner {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	db.OpenEveDatabase().Where("project_id = ? and id = ?", project.ID, form.MemberID).Delete(&db.ProjectMember{})
+	c.Redirect(http.StatusFound, "/production/projects")
+}
+
+func openProject(c *gin.Context, projectID uint) {
+	session := sessions.OpenSession(c)
+	session.Set("current_project", projectID)
+	session.Save()
+}
+
+func openProjectHandler(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	project, role := getProject(c, uint(projectID))
+	if len(role) == 0 || project.Archived {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	openProject(c, project.ID)
+	c.Redirect(http.StatusFound, "/production/calculator")
+}
+
+// Goes back to plans kept in session
+func closeProjectHandler(c *gin.Context) {
+	session := sessions.OpenSession(c)
+	session.Delete("current_project")
+	session.Save()
+
+	c.Redirect(http.StatusFound, "/production/calculator")
+}
@@ -0,0 +1,85 @@
+package calculator
+
+import (
+	"sort"
+	"time"
+)
+
+// Chains of reactions run for days, so they are queued in daily batches by default
+const defaultReactionJobLength = 24 * time.Hour
+
+// Reactions in the same stage can run in parallel, each stage waits for the previous one
+type ReactionStage struct {
+	Stage         int
+	Materials     []MaterialInfoFull
+	WallClockTime time.Duration
+}
+
+// Runs of single reaction job fitting into reaction job length, at least one
+func (material *Material) reactionBatchRuns(settings *BlueprintSettings) int64 {
+	runTime := material.jobTime(Job{Runs: 1}, settings)
+	if runTime <= 0 || material.parent.ReactionJobLength <= 0 {
+		return material.runsRequired(material.neededQuantity())
+	}
+
+	return max(1, int64(material.parent.ReactionJobLength/runTime))
+}
+
+// Moon materials are stage 0, reactions using them stage 1 (intermediates),
+// reactions using intermediates stage 2 (composites) and so on
+func (material *Material) reactionStage() int {
+	if material.BlueprintInfo == nil || !material.BlueprintInfo.IsReaction() || !material.parent.hasBlueprintSettings(material.BlueprintInfo.ID) {
+		return 0
+	}
+
+	stage := 0
+	for _, submaterial := range material.Submaterials {
+		if input, exists := material.parent.Materials[submaterial.MaterialId]; exists {
+			if inputStage := input.reactionStage(); inputStage > stage {
+				stage = inputStage
+			}
+		}
+	}
+
+	return stage + 1
+}
+
+// Reactions built in plan, grouped by stage of the chain
+func GroupReactionStages(materials []MaterialInfoFull) []ReactionStage {
+	result := make([]ReactionStage, 0)
+
+	for _, material := range materials {
+		stage := material.BuildInfo.ReactionStage
+		if !material.IsBuilt || stage == 0 {
+			continue
+		}
+
+		for len(result) < stage {
+			result = append(result, ReactionStage{Stage: len(result) + 1})
+		}
+
+		result[stage-1].Materials = append(result[stage-1].Materials, material)
+		if material.BuildInfo.WallClockTime > result[stage-1].WallClockTime {
+			result[stage-1].WallClockTime = material.BuildInfo.WallClockTime
+		}
+	}
+
+	for _, stage := range result {
+		sort.Slice(stage.Materials, func(i, j int) bool {
+			return stage.Materials[i].MaterialName < stage.Materials[j].MaterialName
+		})
+	}
+
+	return result
+}
+
+// Time to run whole chain, with stages one after another
+func ReactionChainTime(stages []ReactionStage) time.Duration {
+	var result time.Duration
+
+	for _, stage := range stages {
+		result += stage.WallClockTime
+	}
+
+	return result
+}
@@ -0,0 +1,210 @@
+package calculator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mgibula/eve-industry/server/db"
+)
+
+// Blueprint to build, used when calculator is driven without session, e.g. through API.
+// Blueprint can be given by ID or name, blueprint defaults are used when ME/PE are not set.
+// Blueprints with 0 runs are built only as components of other ones
+type BuildRequest struct {
+	BlueprintID   uint64          `json:"blueprint_id"`
+	BlueprintName string          `json:"blueprint_name"`
+	Runs          int64           `json:"runs"`
+	ME            *int32          `json:"me"`
+	PE            *int32          `json:"pe"`
+	Decryptor     uint64          `json:"decryptor"`
+	Facility      FacilityRequest `json:"facility"`
+}
+
+type FacilityRequest struct {
+	Structure  int32   `json:"structure"`
+	Rig        int32   `json:"rig"`
+	Security   int32   `json:"security"`
+	SystemName string  `json:"system_name"`
+	Tax        float64 `json:"tax"`
+}
+
+type BuildProduct struct {
+	MaterialInfoFull
+	Submaterials []MaterialInfo
+}
+
+type BuildResult struct {
+	Products      []BuildProduct
+	Materials     []MaterialInfoFull
+	Excess        []MaterialInfo
+	TotalJobCost  float64
+	TotalTime     time.Duration
+	WallClockTime time.Duration // Longest job, when all jobs run in parallel
+}
+
+func (r *BuildRequest) resolve(c *MaterialCalculator) (db.EVEBlueprint, error) {
+	if r.BlueprintID > 0 {
+		if blueprint, exists := c.Static.Blueprint(r.BlueprintID); exists {
+			return blueprint, nil
+		}
+
+		return db.EVEBlueprint{}, fmt.Errorf("unknown blueprint %d", r.BlueprintID)
+	}
+
+	if blueprint, exists := c.Static.BlueprintByName(r.BlueprintName); exists {
+		return blueprint, nil
+	}
+
+	if blueprint, exists := c.Static.BlueprintForProductName(r.BlueprintName); exists {
+		return blueprint, nil
+	}
+
+	return db.EVEBlueprint{}, fmt.Errorf("unknown blueprint %q", r.BlueprintName)
+}
+
+func (r *BuildRequest) facility(c *MaterialCalculator) (Facility, error) {
+	result := NewFacility(r.Facility.Structure, r.Facility.Rig, r.Facility.Security)
+	result.Tax = r.Facility.Tax
+
+	if len(r.Facility.SystemName) > 0 {
+		system, exists := c.Static.SystemByName(r.Facility.SystemName)
+		if !exists {
+			return result, fmt.Errorf("unknown system %q", r.Facility.SystemName)
+		}
+
+		result.SystemID = system.ID
+		result.SystemName = system.SystemName
+	}
+
+	return result, nil
+}
+
+// Limits of single request. Material quantities of the largest blueprints stay far from int64 overflow
+// and number of jobs split for invented copies or reaction batches stays small enough to answer quickly
+const (
+	maxRequestRuns     = 100000
+	maxRequestQuantity = 10000000
+)
+
+// Checks values that the calculator would otherwise accept silently
+func (r *BuildRequest) validate(c *MaterialCalculator, blueprint db.EVEBlueprint) error {
+	if r.Runs < 0 {
+		return fmt.Errorf("runs must not be negative, got %d", r.Runs)
+	}
+
+	if r.Runs > maxRequestRuns {
+		return fmt.Errorf("runs must not be above %d, got %d", maxRequestRuns, r.Runs)
+	}
+
+	if quantity := r.Runs * blueprint.ManufacturingProductOutputQuantity; quantity > maxRequestQuantity {
+		return fmt.Errorf("%s quantity must not be above %d, got %d", blueprint.ManufacturingProductName, maxRequestQuantity, quantity)
+	}
+
+	if r.ME != nil && (*r.ME < 0 || *r.ME > 10) {
+		return fmt.Errorf("me must be between 0 and 10, got %d", *r.ME)
+	}
+
+	if r.PE != nil && (*r.PE < 0 || *r.PE > 20) {
+		return fmt.Errorf("pe must be between 0 and 20, got %d", *r.PE)
+	}
+
+	if r.Decryptor > 0 && c.getDecryptor(r.Decryptor) == nil {
+		return fmt.Errorf("unknown decryptor %d", r.Decryptor)
+	}
+
+	return nil
+}
+
+// Runs calculator for given blueprints, with default skills
+func Calculate(requests []BuildRequest) (BuildResult, error) {
+	calculator := NewMaterialCalculator()
+	blueprints := make([]db.EVEBlueprint, 0, len(requests))
+
+	for _, request := range requests {
+		blueprint, err := request.resolve(&calculator)
+		if err != nil {
+			return BuildResult{}, err
+		}
+
+		if err := request.validate(&calculator, blueprint); err != nil {
+			return BuildResult{}, err
+		}
+
+		facility, err := request.facility(&calculator)
+		if err != nil {
+			return BuildResult{}, err
+		}
+
+		me := blueprint.GetDefaultME()
+		if request.ME != nil {
+			me = *request.ME
+		}
+
+		pe := blueprint.GetDefaultPE()
+		if request.PE != nil {
+			pe = *request.PE
+		}
+
+		if decryptor := calculator.getDecryptor(request.Decryptor); decryptor != nil && request.ME == nil && request.PE == nil {
+			me += decryptor.MEModifier
+			pe += decryptor.PEModifier
+		}
+
+		// Blueprint listed more than once is built with settings of first entry
+		if !calculator.hasBlueprintSettings(blueprint.ID) {
+			calculator.AddBlueprintSettings(blueprint.ID, me, pe, request.Decryptor, facility)
+		}
+
+		blueprints = append(blueprints, blueprint)
+	}
+
+	for i, blueprint := range blueprints {
+		if requests[i].Runs > 0 {
+			calculator.AddQuantity(blueprint.ManufacturingProductId, blueprint.ManufacturingProductName, blueprint.ManufacturingProductOutputQuantity*requests[i].Runs, true)
+		}
+	}
+
+	calculator.Resolve()
+
+	result := BuildResult{
+		Products:     make([]BuildProduct, 0),
+		Materials:    calculator.GetAllMaterials(),
+		Excess:       make([]MaterialInfo, 0),
+		TotalJobCost: calculator.GetTotalJobCost(),
+	}
+
+	for i, blueprint := range blueprints {
+		if requests[i].Runs == 0 {
+			continue
+		}
+
+		result.Products = append(result.Products, BuildProduct{
+			MaterialInfoFull: getMaterialInfo(blueprint.ManufacturingProductId, result.Materials),
+			Submaterials:     calculator.GetMaterialsFor(blueprint.ManufacturingProductId),
+		})
+	}
+
+	for _, material := range result.Materials {
+		result.TotalTime += material.BuildInfo.TotalTime
+		if material.BuildInfo.WallClockTime > result.WallClockTime {
+			result.WallClockTime = material.BuildInfo.WallClockTime
+		}
+
+		if material.Excess > 0 {
+			result.Excess = append(result.Excess, MaterialInfo{
+				MaterialID:          material.MaterialID,
+				MaterialName:        material.MaterialName,
+				Quantity:            material.Excess,
+				MaterialBlueprintID: material.MaterialBlueprintID,
+				IsBuilt:             material.IsBuilt,
+			})
+		}
+	}
+
+	sort.Slice(result.Excess, func(i, j int) bool {
+		return result.Excess[i].MaterialName < result.Excess[j].MaterialName
+	})
+
+	return result, nil
+}
@@ -0,0 +1,187 @@
+package calculator
+
+// JSON representation of BuildResult, shared by API and command line output. Durations are in seconds
+type BuildResponse struct {
+	Products      []ProductResponse          `json:"products"`
+	Materials     []MaterialResponse         `json:"materials"`
+	Excess        []MaterialQuantityResponse `json:"excess"`
+	TotalJobCost  float64                    `json:"total_job_cost"`
+	TotalTime     float64                    `json:"total_time"`
+	WallClockTime float64                    `json:"wall_clock_time"`
+}
+
+type ProductResponse struct {
+	MaterialResponse
+	Submaterials []MaterialQuantityResponse `json:"submaterials"`
+}
+
+type MaterialQuantityResponse struct {
+	MaterialID  uint64 `json:"material_id"`
+	Name        string `json:"name"`
+	Quantity    int64  `json:"quantity"`
+	BlueprintID uint64 `json:"blueprint_id"`
+	IsBuilt     bool   `json:"is_built"`
+}
+
+type MaterialResponse struct {
+	MaterialID      uint64             `json:"material_id"`
+	Name            string             `json:"name"`
+	Quantity        int64              `json:"quantity"`
+	Excess          int64              `json:"excess"`
+	Have            int64              `json:"have"`
+	Missing         int64              `json:"missing"`
+	BlueprintID     uint64             `json:"blueprint_id"`
+	BlueprintName   string             `json:"blueprint_name"`
+	IsBuilt         bool               `json:"is_built"`
+	IsBlueprintCopy bool               `json:"is_blueprint_copy"`
+	GroupName       string             `json:"group_name"`
+	CategoryName    string             `json:"category_name"`
+	Volume          float64            `json:"volume"`
+	Build           *BuildResponseInfo `json:"build,omitempty"` // Only for built materials
+}
+
+type BuildResponseInfo struct {
+	Runs          int64              `json:"runs"`
+	ME            int32              `json:"me"`
+	PE            int32              `json:"pe"`
+	Jobs          []JobResponse      `json:"jobs"`
+	TotalTime     float64            `json:"total_time"`
+	WallClockTime float64            `json:"wall_clock_time"`
+	TotalCost     float64            `json:"total_cost"`
+	MissingCopies int64              `json:"missing_copies"`
+	MissingRuns   int64              `json:"missing_runs"`
+	Underway      int64              `json:"underway"`
+	ReactionStage int                `json:"reaction_stage"`
+	Invention     *InventionResponse `json:"invention,omitempty"`
+}
+
+type JobResponse struct {
+	Runs      int64   `json:"runs"`
+	ME        int32   `json:"me"`
+	PE        int32   `json:"pe"`
+	Inventory bool    `json:"inventory"`
+	Missing   bool    `json:"missing"`
+	Time      float64 `json:"time"`
+	Cost      float64 `json:"cost"`
+}
+
+type InventionResponse struct {
+	BlueprintID   uint64                     `json:"blueprint_id"`
+	BlueprintName string                     `json:"blueprint_name"`
+	Probability   float64                    `json:"probability"`
+	RunsPerCopy   int64                      `json:"runs_per_copy"`
+	Copies        int64                      `json:"copies"`
+	Attempts      int64                      `json:"attempts"`
+	CopyingTime   float64                    `json:"copying_time"`
+	InventionTime float64                    `json:"invention_time"` // Single attempt
+	DecryptorID   uint64                     `json:"decryptor_id"`
+	DecryptorName string                     `json:"decryptor_name"`
+	Materials     []MaterialQuantityResponse `json:"materials"`
+}
+
+func NewBuildResponse(result BuildResult) BuildResponse {
+	response := BuildResponse{
+		Products:      make([]ProductResponse, 0, len(result.Products)),
+		Materials:     make([]MaterialResponse, 0, len(result.Materials)),
+		Excess:        newQuantityResponses(result.Excess),
+		TotalJobCost:  result.TotalJobCost,
+		TotalTime:     result.TotalTime.Seconds(),
+		WallClockTime: result.WallClockTime.Seconds(),
+	}
+
+	for _, product := range result.Products {
+		response.Products = append(response.Products, ProductResponse{
+			MaterialResponse: newMaterialResponse(product.MaterialInfoFull),
+			Submaterials:     newQuantityResponses(product.Submaterials),
+		})
+	}
+
+	for _, material := range result.Materials {
+		response.Materials = append(response.Materials, newMaterialResponse(material))
+	}
+
+	return response
+}
+
+func newQuantityResponses(materials []MaterialInfo) []MaterialQuantityResponse {
+	result := make([]MaterialQuantityResponse, 0, len(materials))
+
+	for _, material := range materials {
+		result = append(result, MaterialQuantityResponse{
+			MaterialID:  material.MaterialID,
+			Name:        material.MaterialName,
+			Quantity:    material.Quantity,
+			BlueprintID: material.MaterialBlueprintID,
+			IsBuilt:     material.IsBuilt,
+		})
+	}
+
+	return result
+}
+
+func newMaterialResponse(material MaterialInfoFull) MaterialResponse {
+	result := MaterialResponse{
+		MaterialID:      material.MaterialID,
+		Name:            material.MaterialName,
+		Quantity:        material.Quantity,
+		Excess:          material.Excess,
+		Have:            material.Have,
+		Missing:         material.Missing,
+		BlueprintID:     material.MaterialBlueprintID,
+		BlueprintName:   material.MaterialBlueprintName,
+		IsBuilt:         material.IsBuilt,
+		IsBlueprintCopy: material.IsBlueprintCopy,
+		GroupName:       material.GroupName,
+		CategoryName:    material.CategoryName,
+		Volume:          material.Volume,
+	}
+
+	if !material.IsBuilt {
+		return result
+	}
+
+	build := material.BuildInfo
+	result.Build = &BuildResponseInfo{
+		Runs:          build.Runs,
+		ME:            build.ME,
+		PE:            build.PE,
+		Jobs:          make([]JobResponse, 0, len(build.Jobs)),
+		TotalTime:     build.TotalTime.Seconds(),
+		WallClockTime: build.WallClockTime.Seconds(),
+		TotalCost:     build.TotalCost,
+		MissingCopies: build.MissingCopies,
+		MissingRuns:   build.MissingRuns,
+		Underway:      build.Underway,
+		ReactionStage: build.ReactionStage,
+	}
+
+	for _, job := range build.Jobs {
+		result.Build.Jobs = append(result.Build.Jobs, JobResponse{
+			Runs:      job.Runs,
+			ME:        job.ME,
+			PE:        job.PE,
+			Inventory: job.Inventory,
+			Missing:   job.Missing,
+			Time:      job.Time.Seconds(),
+			Cost:      job.Cost,
+		})
+	}
+
+	if invention := build.Invention; invention != nil {
+		result.Build.Invention = &InventionResponse{
+			BlueprintID:   invention.BlueprintID,
+			BlueprintName: invention.BlueprintName,
+			Probability:   invention.Probability,
+			RunsPerCopy:   invention.RunsPerCopy,
+			Copies:        invention.Copies,
+			Attempts:      invention.Attempts,
+			CopyingTime:   invention.CopyingTime.Seconds(),
+			InventionTime: invention.InventionTime.Seconds(),
+			DecryptorID:   invention.DecryptorID,
+			DecryptorName: invention.DecryptorName,
+			Materials:     newQuantityResponses(invention.Materials),
+		}
+	}
+
+	return result
+}
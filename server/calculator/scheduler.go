@@ -0,0 +1,304 @@
+package calculator
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgibula/eve-industry/server/db"
+	"github.com/mgibula/eve-industry/server/layout"
+	"gorm.io/gorm"
+)
+
+const (
+	ActivityNameManufacturing = "Manufacturing"
+	ActivityNameReaction      = "Reaction"
+	ActivityNameCopying       = "Copying"
+	ActivityNameInvention     = "Invention"
+)
+
+const (
+	slotManufacturing = iota
+	slotReaction
+	slotResearch
+	slotKinds
+)
+
+type SchedulerCharacter struct {
+	ID     uint64
+	Name   string // Empty for assumed character, when nobody is logged in
+	Skills IndustrySkills
+	Slots  JobSlots
+}
+
+// Times are counted from project start
+type ScheduledJob struct {
+	CharacterID   uint64
+	CharacterName string
+	Activity      string
+	MaterialID    uint64
+	MaterialName  string
+	Runs          int64
+	Slot          int // Slot of given kind, counted from 1
+	Start         time.Duration
+	End           time.Duration
+	MissingSkills bool // Nobody has required skills, job is assigned anyway
+}
+
+type Schedule struct {
+	Jobs           []ScheduledJob
+	Characters     []SchedulerCharacter
+	CompletionTime time.Duration
+}
+
+type scheduler struct {
+	characters []SchedulerCharacter
+	freeAt     [slotKinds][][]time.Duration // Time each slot becomes free, by kind, character and slot
+	jobs       []ScheduledJob
+}
+
+// Logged characters with imported skills. Without any, single character with default skills is assumed
+func LoadSchedulerCharacters(evedb *gorm.DB, characters []db.ESIUser) []SchedulerCharacter {
+	result := make([]SchedulerCharacter, 0, len(characters))
+
+	for _, character := range characters {
+		skills, loaded := LoadCharacterSkills(evedb, character.ID)
+		if !loaded {
+			continue
+		}
+
+		result = append(result, SchedulerCharacter{
+			ID:     character.ID,
+			Name:   character.CharacterName,
+			Skills: skills,
+			Slots:  skills.Slots(),
+		})
+	}
+
+	if len(result) == 0 {
+		result = append(result, defaultSchedulerCharacter())
+	}
+
+	return result
+}
+
+func defaultSchedulerCharacter() SchedulerCharacter {
+	return SchedulerCharacter{
+		Skills: DefaultSkills(),
+		Slots:  DefaultSlots(),
+	}
+}
+
+func newScheduler(characters []SchedulerCharacter) *scheduler {
+	result := scheduler{
+		characters: characters,
+		jobs:       make([]ScheduledJob, 0),
+	}
+
+	for _, character := range characters {
+		result.freeAt[slotManufacturing] = append(result.freeAt[slotManufacturing], make([]time.Duration, character.Slots.Manufacturing))
+		result.freeAt[slotReaction] = append(result.freeAt[slotReaction], make([]time.Duration, character.Slots.Reactions))
+		result.freeAt[slotResearch] = append(result.freeAt[slotResearch], make([]time.Duration, character.Slots.Research))
+	}
+
+	return &result
+}
+
+// Puts job into slot where it ends earliest, among characters having required skills.
+// Duration depends on skills of the character running the job
+func (s *scheduler) assign(kind int, job ScheduledJob, duration func(skills IndustrySkills) time.Duration, ready time.Duration, required []db.EVEActivitySkill) time.Duration {
+	qualified := make([]int, 0, len(s.characters))
+	for i, character := range s.characters {
+		if len(character.Skills.MissingSkills(required)) == 0 {
+			qualified = append(qualified, i)
+		}
+	}
+
+	if len(qualified) == 0 {
+		job.MissingSkills = true
+		for i := range s.characters {
+			qualified = append(qualified, i)
+		}
+	}
+
+	bestCharacter, bestSlot := -1, -1
+	var bestStart, bestEnd time.Duration
+
+	for _, i := range qualified {
+		length := duration(s.characters[i].Skills)
+
+		for slot, free := range s.freeAt[kind][i] {
+			start := ready
+			if free > start {
+				start = free
+			}
+
+			if bestCharacter < 0 || start+length < bestEnd {
+				bestCharacter, bestSlot, bestStart, bestEnd = i, slot, start, start+length
+			}
+		}
+	}
+
+	job.CharacterID = s.characters[bestCharacter].ID
+	job.CharacterName = s.characters[bestCharacter].Name
+	job.Slot = bestSlot + 1
+	job.Start = bestStart
+	job.End = bestEnd
+
+	s.freeAt[kind][bestCharacter][bestSlot] = job.End
+	s.jobs = append(s.jobs, job)
+
+	return job.End
+}
+
+func (s *scheduler) researchSlots() int64 {
+	var result int64
+
+	for _, character := range s.characters {
+		result += int64(character.Slots.Research)
+	}
+
+	return result
+}
+
+// Jobs are assigned in dependency order, every job starts after all jobs of its inputs are done.
+// Jobs already installed in game are not included. Without characters, single character with default skills is assumed
+func (c *MaterialCalculator) GetSchedule(characters []SchedulerCharacter) Schedule {
+	if len(characters) == 0 {
+		characters = []SchedulerCharacter{defaultSchedulerCharacter()}
+	}
+
+	s := newScheduler(characters)
+	finished := make(map[uint64]time.Duration)
+
+	// Topological order has parents first, inputs are scheduled before them
+	order := c.topologicalOrder()
+	for i := len(order) - 1; i >= 0; i-- {
+		material := order[i]
+		if material.BlueprintInfo == nil || len(material.Jobs) == 0 {
+			continue
+		}
+
+		settings := c.getBlueprintSettings(material.BlueprintInfo.ID)
+		if settings == nil {
+			continue
+		}
+
+		var ready time.Duration
+		for _, submaterial := range material.Submaterials {
+			if finished[submaterial.MaterialId] > ready {
+				ready = finished[submaterial.MaterialId]
+			}
+		}
+
+		inventionReady := ready
+		if invention := material.getInventionInfo(settings); invention != nil {
+			inventionReady = s.scheduleInvention(material, invention)
+			if ready > inventionReady {
+				inventionReady = ready
+			}
+		}
+
+		kind, activity := slotManufacturing, ActivityNameManufacturing
+		if material.BlueprintInfo.IsReaction() {
+			kind, activity = slotReaction, ActivityNameReaction
+		}
+
+		for _, job := range material.Jobs {
+			jobReady := ready
+			if !job.Inventory {
+				jobReady = inventionReady
+			}
+
+			end := s.assign(kind, ScheduledJob{
+				Activity:     activity,
+				MaterialID:   material.MaterialID,
+				MaterialName: material.MaterialName,
+				Runs:         job.Runs,
+			}, func(skills IndustrySkills) time.Duration {
+				return material.jobTimeWithSkills(job, settings, skills)
+			}, jobReady, material.RequiredSkills)
+
+			if end > finished[material.MaterialID] {
+				finished[material.MaterialID] = end
+			}
+		}
+	}
+
+	result := Schedule{
+		Jobs:       s.jobs,
+		Characters: characters,
+	}
+
+	for _, job := range result.Jobs {
+		if job.End > result.CompletionTime {
+			result.CompletionTime = job.End
+		}
+	}
+
+	sort.SliceStable(result.Jobs, func(i, j int) bool {
+		if result.Jobs[i].Start != result.Jobs[j].Start {
+			return result.Jobs[i].Start < result.Jobs[j].Start
+		}
+
+		return result.Jobs[i].CharacterName < result.Jobs[j].CharacterName
+	})
+
+	return result
+}
+
+// Copies for all attempts are made in one job, attempts are then split evenly across research slots.
+// Returns time when all invented copies are ready
+func (s *scheduler) scheduleInvention(material *Material, invention *InventionInfo) time.Duration {
+	copied := s.assign(slotResearch, ScheduledJob{
+		Activity:     ActivityNameCopying,
+		MaterialID:   invention.BlueprintID,
+		MaterialName: invention.BlueprintName,
+		Runs:         invention.Attempts,
+	}, func(skills IndustrySkills) time.Duration {
+		return material.copyingTime(invention.Attempts, skills)
+	}, 0, nil)
+
+	jobs := min(invention.Attempts, s.researchSlots())
+	result := copied
+
+	for i := int64(0); i < jobs; i++ {
+		// Remainder goes to first jobs
+		runs := invention.Attempts / jobs
+		if i < invention.Attempts%jobs {
+			runs++
+		}
+
+		end := s.assign(slotResearch, ScheduledJob{
+			Activity:     ActivityNameInvention,
+			MaterialID:   material.MaterialID,
+			MaterialName: material.MaterialName,
+			Runs:         runs,
+		}, func(skills IndustrySkills) time.Duration {
+			return material.inventionTime(skills) * time.Duration(runs)
+		}, copied, invention.Skills)
+
+		if end > result {
+			result = end
+		}
+	}
+
+	return result
+}
+
+func renderSchedule(c *gin.Context) {
+	plans := getProductionPlans(c)
+	calculator := plans.newCalculator()
+
+	layout.Render(c, "ajax/schedule.tmpl", gin.H{
+		"schedule": calculator.GetSchedule(LoadSchedulerCharacters(db.OpenEveDatabase(), plans.characters)),
+	})
+}
+
+func scheduleHandler(c *gin.Context) {
+	plans := getProductionPlans(c)
+	calculator := plans.newCalculator()
+
+	c.JSON(http.StatusOK, calculator.GetSchedule(LoadSchedulerCharacters(db.OpenEveDatabase(), plans.characters)))
+}
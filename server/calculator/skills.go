@@ -0,0 +1,145 @@
+package calculator
+
+import (
+	"fmt"
+
+	"github.com/mgibula/eve-industry/server/db"
+	"gorm.io/gorm"
+)
+
+const (
+	SkillIndustry         = 3380
+	SkillAdvancedIndustry = 3388
+	SkillReactions        = 45746
+
+	SkillMassProduction              = 3387
+	SkillAdvancedMassProduction      = 24625
+	SkillMassReactions               = 45748
+	SkillAdvancedMassReactions       = 45749
+	SkillLaboratoryOperation         = 3406
+	SkillAdvancedLaboratoryOperation = 24624
+)
+
+type IndustrySkills struct {
+	Industry         int32
+	AdvancedIndustry int32
+	Reactions        int32
+	Science          int32            // Level assumed for science skills missing in Levels
+	Encryption       int32            // Level assumed for encryption skills missing in Levels
+	Levels           map[uint64]int32 // Trained levels by skill ID
+}
+
+func DefaultSkills() IndustrySkills {
+	return IndustrySkills{
+		Industry:         5,
+		AdvancedIndustry: 5,
+		Reactions:        5,
+		Science:          5,
+		Encryption:       5,
+		Levels:           make(map[uint64]int32),
+	}
+}
+
+// Skills imported from ESI, second value is false when character has no skills imported yet
+func LoadCharacterSkills(evedb *gorm.DB, characterID uint64) (IndustrySkills, bool) {
+	var skills []db.CharacterSkill
+	evedb.Where("character_id = ?", characterID).Find(&skills)
+
+	result := IndustrySkills{
+		Levels: make(map[uint64]int32, len(skills)),
+	}
+
+	for _, skill := range skills {
+		result.Levels[skill.SkillId] = skill.Level
+	}
+
+	result.Industry = result.Levels[SkillIndustry]
+	result.AdvancedIndustry = result.Levels[SkillAdvancedIndustry]
+	result.Reactions = result.Levels[SkillReactions]
+
+	return result, len(skills) > 0
+}
+
+// Required skills not trained to required level, as readable "Skill N" strings.
+// Skills missing in Levels are checked against assumed levels, so defaults pass every requirement
+func (s IndustrySkills) MissingSkills(required []db.EVEActivitySkill) []string {
+	result := make([]string, 0)
+
+	for i, skill := range required {
+		if s.Level(&required[i]) < skill.Level {
+			result = append(result, fmt.Sprintf("%s %d", skill.SkillName, skill.Level))
+		}
+	}
+
+	return result
+}
+
+func (s IndustrySkills) Level(skill *db.EVEActivitySkill) int32 {
+	if level, exists := s.Levels[skill.SkillId]; exists {
+		return level
+	}
+
+	if skill.IsEncryption() {
+		return s.Encryption
+	}
+
+	return s.Science
+}
+
+// Multiplier applied to blueprint job time from character skills
+func (s IndustrySkills) TimeMultiplier(blueprint *db.EVEBlueprint, required []db.EVEActivitySkill) float64 {
+	if blueprint.IsReaction() {
+		return 1.0 - float64(s.Reactions)*0.04
+	}
+
+	multiplier := (1.0 - float64(s.Industry)*0.04) * (1.0 - float64(s.AdvancedIndustry)*0.03)
+
+	// Every required science skill gives 1% bonus per level
+	for i := range required {
+		if required[i].IsScience() {
+			multiplier *= 1.0 - float64(s.Level(&required[i]))*0.01
+		}
+	}
+
+	return multiplier
+}
+
+// Invention chance multiplier from datacore science skills and encryption skill
+func (s IndustrySkills) InventionMultiplier(required []db.EVEActivitySkill) float64 {
+	multiplier := 1.0
+
+	for i := range required {
+		if required[i].IsEncryption() {
+			multiplier += float64(s.Level(&required[i])) / 40.0
+		} else if required[i].IsScience() {
+			multiplier += float64(s.Level(&required[i])) / 30.0
+		}
+	}
+
+	return multiplier
+}
+
+// Job slots available to character, research slots are used for copying and invention
+type JobSlots struct {
+	Manufacturing int
+	Reactions     int
+	Research      int
+}
+
+// Every character has one slot of each kind, slot skills add one per level
+func (s IndustrySkills) Slots() JobSlots {
+	return JobSlots{
+		Manufacturing: 1 + int(s.Levels[SkillMassProduction]+s.Levels[SkillAdvancedMassProduction]),
+		Reactions:     1 + int(s.Levels[SkillMassReactions]+s.Levels[SkillAdvancedMassReactions]),
+		Research:      1 + int(s.Levels[SkillLaboratoryOperation]+s.Levels[SkillAdvancedLaboratoryOperation]),
+	}
+}
+
+// Slots with all slot skills at level 5, matches DefaultSkills
+func DefaultSlots() JobSlots {
+	return JobSlots{
+		Manufacturing: 11,
+		Reactions:     11,
+		Research:      11,
+	}
+}
@@ -0,0 +1,70 @@
+package calculator
+
+import (
+	"github.com/mgibula/eve-industry/server/db"
+)
+
+// Items held in stockpile location by location owner and owner's corporation
+func (c *MaterialCalculator) LoadStock(locationID uint) {
+	c.Stock = make(map[uint64]int64)
+
+	var location db.Location
+	if c.EveDB.Take(&location, locationID).Error != nil {
+		return
+	}
+
+	var owner db.ESIUser
+	c.EveDB.Take(&owner, location.CharacterId)
+
+	query := c.EveDB.Model(&db.Asset{}).Select("type_id, sum(quantity) as quantity")
+
+	// Character hangar has no divisions
+	if location.Hangar > 0 {
+		query = query.Where("owner_id = ? and is_corporation = ? and hangar = ?", owner.CorporationId, true, location.Hangar)
+	} else {
+		query = query.Where("(owner_id = ? and is_corporation = ?) or (owner_id = ? and is_corporation = ?)", location.CharacterId, false, owner.CorporationId, true)
+	}
+
+	if location.StationId > 0 {
+		query = query.Where("station_id = ?", location.StationId)
+	} else if location.SystemId > 0 {
+		query = query.Where("system_id = ?", location.SystemId)
+	}
+
+	type stock struct {
+		TypeId   uint64
+		Quantity int64
+	}
+
+	var result []stock
+	query.Group("type_id").Scan(&result)
+
+	for _, item := range result {
+		c.Stock[item.TypeId] = item.Quantity
+	}
+}
+
+// Stock is used for intermediates only, requested products are always built
+func (material *Material) neededQuantity() int64 {
+	fromStock := material.stockUsed()
+	return max(material.TotalQuantity-fromStock, material.RequestedQuantity)
+}
+
+func (material *Material) stockUsed() int64 {
+	return min(material.parent.Stock[material.MaterialID], max(0, material.TotalQuantity-material.RequestedQuantity))
+}
+
+// Runs of manufacturing and reaction jobs running for given characters and corporations
+func (c *MaterialCalculator) LoadUnderwayJobs(characterIDs []uint64, corporationIDs []uint64) {
+	c.Underway = make(map[uint64]int64)
+
+	for _, job := range db.FindIndustryJobs(c.EveDB, characterIDs, corporationIDs) {
+		if !job.IsUnderway() {
+			continue
+		}
+
+		if job.ActivityId == db.ActivityManufacturing || job.ActivityId == db.ActivityReaction {
+			c.Underway[job.BlueprintTypeId] += job.Runs
+		}
+	}
+}
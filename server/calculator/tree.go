@@ -0,0 +1,125 @@
+package calculator
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgibula/eve-industry/server/layout"
+)
+
+// Node of bill of materials. Quantity is the part needed by parent node, totals of the
+// material in whole plan (jobs, excess) are in Info
+type MaterialTreeNode struct {
+	MaterialID   uint64
+	MaterialName string
+	Quantity     int64
+	Info         MaterialInfoFull
+	Children     []MaterialTreeNode
+}
+
+func (n MaterialTreeNode) HasChildren() bool {
+	return len(n.Children) > 0
+}
+
+// Trees of all requested products
+func (c *MaterialCalculator) GetMaterialTree() []MaterialTreeNode {
+	materials := c.GetAllMaterials()
+	result := make([]MaterialTreeNode, 0)
+
+	for _, material := range c.Materials {
+		if material.RequestedQuantity > 0 {
+			result = append(result, c.buildTreeNode(material, material.RequestedQuantity, materials, make(map[uint64]bool)))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MaterialName < result[j].MaterialName
+	})
+
+	return result
+}
+
+// Tree of single item, with its whole quantity in plan
+func (c *MaterialCalculator) GetMaterialTreeFor(itemID uint64) *MaterialTreeNode {
+	material, exists := c.Materials[itemID]
+	if !exists {
+		return nil
+	}
+
+	node := c.buildTreeNode(material, material.TotalQuantity, c.GetAllMaterials(), make(map[uint64]bool))
+	return &node
+}
+
+// Intermediates shared by several parents are split proportionally to the quantity each parent needs
+func (c *MaterialCalculator) buildTreeNode(material *Material, quantity int64, materials []MaterialInfoFull, path map[uint64]bool) MaterialTreeNode {
+	node := MaterialTreeNode{
+		MaterialID:   material.MaterialID,
+		MaterialName: material.MaterialName,
+		Quantity:     quantity,
+		Info:         getMaterialInfo(material.MaterialID, materials),
+		Children:     make([]MaterialTreeNode, 0),
+	}
+
+	if !node.Info.IsBuilt || material.TotalQuantity <= 0 || path[material.MaterialID] {
+		return node
+	}
+
+	path[material.MaterialID] = true
+	defer delete(path, material.MaterialID)
+
+	share := float64(quantity) / float64(material.TotalQuantity)
+	for _, submaterial := range material.Submaterials {
+		child, exists := c.Materials[submaterial.MaterialId]
+		if !exists {
+			continue
+		}
+
+		childQuantity := int64(math.Ceil(float64(material.SubmaterialQuantites[submaterial.MaterialId]) * share))
+		if childQuantity > 0 {
+			node.Children = append(node.Children, c.buildTreeNode(child, childQuantity, materials, path))
+		}
+	}
+
+	sort.Slice(node.Children, func(i, j int) bool {
+		return node.Children[i].MaterialName < node.Children[j].MaterialName
+	})
+
+	return node
+}
+
+func renderMaterialTree(c *gin.Context) {
+	plans := getProductionPlans(c)
+	calculator := plans.newCalculator()
+
+	layout.Render(c, "ajax/material-tree.tmpl", gin.H{
+		"tree": calculator.GetMaterialTree(),
+	})
+}
+
+// Trees of all requested products, or tree of single item when item_id is given
+func materialTreeHandler(c *gin.Context) {
+	type params struct {
+		ItemID uint64 `form:"item_id"`
+	}
+
+	var form params
+	c.Bind(&form)
+
+	plans := getProductionPlans(c)
+	calculator := plans.newCalculator()
+
+	if form.ItemID > 0 {
+		node := calculator.GetMaterialTreeFor(form.ItemID)
+		if node == nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		c.JSON(http.StatusOK, node)
+		return
+	}
+
+	c.JSON(http.StatusOK, calculator.GetMaterialTree())
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mgibula/eve-industry/server/calculator"
+)
+
+// Build list line: "Blueprint Name [xRuns] [ME/TE]"
+var buildLine = regexp.MustCompile(`^(.+?)(?:\s+x(\d+))?(?:\s+(\d+)/(\d+))?$`)
+
+// Runs calculator on build list read from file (or stdin when path is "-") and prints results
+func Run(path string, format string, out io.Writer) error {
+	input := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		input = file
+	}
+
+	requests, err := ParseBuildList(input)
+	if err != nil {
+		return err
+	}
+
+	result, err := calculator.Calculate(requests)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "text":
+		return writeText(out, &result)
+	case "csv":
+		return writeCSV(out, &result)
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(calculator.NewBuildResponse(result))
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// Empty lines and lines starting with # are skipped, runs default to 1
+func ParseBuildList(input io.Reader) ([]calculator.BuildRequest, error) {
+	result := make([]calculator.BuildRequest, 0)
+
+	scanner := bufio.NewScanner(input)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		match := buildLine.FindStringSubmatch(text)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: cannot parse %q", line, text)
+		}
+
+		request := calculator.BuildRequest{
+			BlueprintName: match[1],
+			Runs:          1,
+		}
+
+		if len(match[2]) > 0 {
+			request.Runs, _ = strconv.ParseInt(match[2], 10, 64)
+		}
+
+		if len(match[3]) > 0 {
+			me, _ := strconv.ParseInt(match[3], 10, 32)
+			te, _ := strconv.ParseInt(match[4], 10, 32)
+			request.ME = new(int32)
+			request.PE = new(int32)
+			*request.ME = int32(me)
+			*request.PE = int32(te)
+		}
+
+		result = append(result, request)
+	}
+
+	return result, scanner.Err()
+}
+
+func writeText(out io.Writer, result *calculator.BuildResult) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "Materials to buy:")
+	for _, material := range result.Materials {
+		if !material.IsBuilt && !material.IsBlueprintCopy {
+			fmt.Fprintf(writer, "  %s\t%d\n", material.MaterialName, material.Quantity)
+		}
+	}
+
+	fmt.Fprintln(writer, "\nBlueprint copies for invention:")
+	for _, material := range result.Materials {
+		if material.IsBlueprintCopy {
+			fmt.Fprintf(writer, "  %s\t%d\n", material.MaterialName, material.Quantity)
+		}
+	}
+
+	fmt.Fprintln(writer, "\nJobs to run:")
+	for _, material := range result.Materials {
+		if material.IsBuilt {
+			fmt.Fprintf(writer, "  %s\t%d runs\t%d jobs\t%s\t%.2f ISK\n", material.MaterialBlueprintName, material.BuildInfo.Runs, len(material.BuildInfo.Jobs), material.BuildInfo.TotalTime.Round(time.Second), material.BuildInfo.TotalCost)
+		}
+	}
+
+	fmt.Fprintln(writer, "\nExcess:")
+	for _, material := range result.Excess {
+		fmt.Fprintf(writer, "  %s\t%d\n", material.MaterialName, material.Quantity)
+	}
+
+	fmt.Fprintf(writer, "\nInstallation fees:\t%.2f ISK\n", result.TotalJobCost)
+	fmt.Fprintf(writer, "Total job time:\t%s\n", result.TotalTime.Round(time.Second))
+	fmt.Fprintf(writer, "Wall clock time:\t%s\n", result.WallClockTime.Round(time.Second))
+
+	return writer.Flush()
+}
+
+// Single table, first column tells which section row belongs to
+func writeCSV(out io.Writer, result *calculator.BuildResult) error {
+	writer := csv.NewWriter(out)
+	writer.Write([]string{"table", "name", "quantity", "runs", "jobs", "time_seconds", "cost"})
+
+	for _, material := range result.Materials {
+		if material.IsBlueprintCopy {
+			writer.Write([]string{"copy", material.MaterialName, strconv.FormatInt(material.Quantity, 10), "", "", "", ""})
+		} else if !material.IsBuilt {
+			writer.Write([]string{"material", material.MaterialName, strconv.FormatInt(material.Quantity, 10), "", "", "", ""})
+		}
+	}
+
+	for _, material := range result.Materials {
+		if material.IsBuilt {
+			writer.Write([]string{
+				"job",
+				material.MaterialBlueprintName,
+				strconv.FormatInt(material.Quantity, 10),
+				strconv.FormatInt(material.BuildInfo.Runs, 10),
+				strconv.Itoa(len(material.BuildInfo.Jobs)),
+				strconv.FormatInt(int64(material.BuildInfo.TotalTime.Seconds()), 10),
+				strconv.FormatFloat(material.BuildInfo.TotalCost, 'f', 2, 64),
+			})
+		}
+	}
+
+	for _, material := range result.Excess {
+		writer.Write([]string{"excess", material.MaterialName, strconv.FormatInt(material.Quantity, 10), "", "", "", ""})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -6,8 +6,6 @@ var (
 	CookDb    = flag.String("cook-database", "", "EVE DB to cook from")
 	ClientId  = flag.String("client-id", "", "EVE API Client ID")
 	SecretKey = flag.String("secret-key", "", "EVE API Secret Key")
+	Calculate = flag.String("calculate", "", "Build list to calculate, - reads from stdin")
+	Format    = flag.String("format", "text", "Calculator output format: text, csv or json")
 )
-
-func init() {
-	flag.Parse()
-}
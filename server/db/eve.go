@@ -4,6 +4,8 @@ import (
 	"encoding/gob"
 	"log"
 	"math"
+	"strings"
+	"sync"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -27,7 +29,7 @@ type EVEStation struct {
 	ID          uint64
 	SystemId    uint64
 	StationName string
-	NPC         bool
+	NPC         bool // Upwell structures are added when found in assets
 }
 
 type EVEBlueprint struct {
@@ -62,6 +64,19 @@ const (
 	MetaGroupStructureTech1   = 54
 )
 
+const (
+	ActivityManufacturing    = 1
+	ActivityResearchTime     = 3
+	ActivityResearchMaterial = 4
+	ActivityCopying          = 5
+	ActivityInvention        = 8
+	ActivityReaction         = 11
+)
+
+const (
+	SkillGroupScience = 270
+)
+
 func (b *EVEBlueprint) GetDefaultME() int32 {
 	if b.Reaction > 0 {
 		return 0
@@ -110,6 +125,15 @@ func (b *EVEBlueprint) IsReaction() bool {
 	return (b.Reaction > 0)
 }
 
+// Activity product is made with, reaction formulas have their own
+func (b *EVEBlueprint) ProductionActivity() uint32 {
+	if b.IsReaction() {
+		return ActivityReaction
+	}
+
+	return ActivityManufacturing
+}
+
 type EVEMaterial struct {
 	ID                              uint `gorm:"primaryKey"`
 	BlueprintId                     uint64
@@ -121,6 +145,69 @@ type EVEMaterial struct {
 	MaterialBlueprintOutputQuantity int64
 }
 
+type EVEType struct {
+	ID             uint64
+	GroupId        uint64 `gorm:"index"`
+	MarketGroupId  uint64
+	Name           string `gorm:"index"`
+	Volume         float64
+	PackagedVolume float64 // Volume in cargo hold, differs from Volume for ships and containers
+	BasePrice      float64
+	Published      bool
+}
+
+type EVEGroup struct {
+	ID         uint64
+	CategoryId uint64 `gorm:"index"`
+	Name       string
+}
+
+type EVECategory struct {
+	ID   uint64
+	Name string
+}
+
+type EVEMarketGroup struct {
+	ID            uint64
+	ParentGroupId uint64
+	Name          string
+}
+
+// Type with names of its group and category
+type EVETypeInfo struct {
+	EVEType
+	GroupName    string
+	CategoryId   uint64
+	CategoryName string
+}
+
+type EVEInventionProduct struct {
+	ID                 uint `gorm:"primaryKey"`
+	BlueprintId        uint64
+	BlueprintName      string
+	ProductBlueprintId uint64 `gorm:"index"`
+	Runs               int64
+	Probability        float32
+}
+
+type EVEActivitySkill struct {
+	ID           uint   `gorm:"primaryKey"`
+	BlueprintId  uint64 `gorm:"index"`
+	ActivityId   uint32
+	SkillId      uint64
+	SkillName    string
+	SkillGroupId uint64
+	Level        int32
+}
+
+func (s *EVEActivitySkill) IsScience() bool {
+	return s.SkillGroupId == SkillGroupScience
+}
+
+func (s *EVEActivitySkill) IsEncryption() bool {
+	return s.IsScience() && strings.HasSuffix(s.SkillName, "Encryption Methods")
+}
+
 type EVEDecryptor struct {
 	ID                  uint64
 	Name                string
@@ -133,6 +220,7 @@ type EVEDecryptor struct {
 type ESIUser struct {
 	ID            uint64
 	CharacterName string
+	CorporationId uint64
 	RefreshToken  string
 	AccessToken   string
 	ValidUntil    time.Time
@@ -145,6 +233,188 @@ type ESICall struct {
 	Response   string
 	ValidUntil time.Time
 	Etag       string
+	Pages      int
+}
+
+type CharacterSkill struct {
+	ID           uint64 `gorm:"primaryKey"`
+	CharacterId  uint64 `gorm:"index:character_skill_idx,unique"`
+	SkillId      uint64 `gorm:"index:character_skill_idx,unique"`
+	Level        int32  // Active level, lower than trained for alpha clones
+	TrainedLevel int32
+	SkillPoints  int64
+}
+
+type OwnedBlueprint struct {
+	ItemId        uint64 `gorm:"primaryKey"`
+	OwnerId       uint64 `gorm:"index"`
+	IsCorporation bool
+	TypeId        uint64 `gorm:"index"`
+	LocationId    uint64
+	LocationFlag  string
+	ME            int32
+	TE            int32
+	Runs          int64 // -1 for originals
+	Quantity      int64
+	IsCopy        bool
+}
+
+// Blueprints owned by character or its corporation - originals first, then highest ME/TE and runs
+func FindOwnedBlueprints(db *gorm.DB, typeID uint64, characterID uint64, corporationID uint64) []OwnedBlueprint {
+	var result []OwnedBlueprint
+
+	db.Where("type_id = ? and ((owner_id = ? and is_corporation = ?) or (owner_id = ? and is_corporation = ?))", typeID, characterID, false, corporationID, true).
+		Order("is_copy, me desc, te desc, runs desc").
+		Find(&result)
+
+	return result
+}
+
+type Asset struct {
+	ItemId        uint64 `gorm:"primaryKey"`
+	OwnerId       uint64 `gorm:"index"`
+	IsCorporation bool
+	TypeId        uint64 `gorm:"index"`
+	LocationId    uint64 // Direct parent - station, structure, container or ship
+	LocationFlag  string
+	Quantity      int64
+	IsSingleton   bool
+	IsCopy        bool
+
+	// Resolved from container hierarchy
+	StationId uint64 `gorm:"index"`
+	SystemId  uint64
+	Hangar    uint32 // Corporation hangar division, 0 for character assets
+}
+
+type IndustryJob struct {
+	JobId           uint64 `gorm:"primaryKey"`
+	OwnerId         uint64 `gorm:"index"`
+	IsCorporation   bool
+	InstallerId     uint64
+	ActivityId      int32
+	BlueprintId     uint64 // Item ID of blueprint used
+	BlueprintTypeId uint64 `gorm:"index"`
+	BlueprintName   string
+	ProductTypeId   uint64
+	ProductName     string
+	Runs            int64
+	Status          string // active, cancelled, delivered, paused, ready or reverted
+	FacilityId      uint64
+	StartDate       time.Time
+	EndDate         time.Time
+}
+
+// Running and finished, but not yet delivered jobs
+func (j *IndustryJob) IsUnderway() bool {
+	return j.Status == "active" || j.Status == "paused" || j.Status == "ready"
+}
+
+func (j *IndustryJob) TimeRemaining() time.Duration {
+	if !j.IsUnderway() {
+		return 0
+	}
+
+	return time.Until(j.EndDate).Round(time.Second)
+}
+
+func (j *IndustryJob) ActivityName() string {
+	switch j.ActivityId {
+	case ActivityManufacturing:
+		return "Manufacturing"
+	case ActivityResearchTime:
+		return "TE research"
+	case ActivityResearchMaterial:
+		return "ME research"
+	case ActivityCopying:
+		return "Copying"
+	case ActivityInvention:
+		return "Invention"
+	case ActivityReaction:
+		return "Reaction"
+	}
+
+	return "Unknown"
+}
+
+// Jobs of given characters and corporations, running ones first
+func FindIndustryJobs(db *gorm.DB, characterIDs []uint64, corporationIDs []uint64) []IndustryJob {
+	var result []IndustryJob
+
+	db.Where("(owner_id in ? and is_corporation = ?) or (owner_id in ? and is_corporation = ?)", characterIDs, false, corporationIDs, true).
+		Order("status in ('active', 'paused', 'ready') desc, end_date").
+		Find(&result)
+
+	return result
+}
+
+type Project struct {
+	gorm.Model
+	Name          string
+	OwnerId       uint64 `gorm:"index"` // Character or corporation
+	IsCorporation bool
+	CreatorId     uint64 // Character that created the project, owner of corporation projects
+	Archived      bool
+	Stockpile     uint
+}
+
+type ProjectPlan struct {
+	ID            uint64 `gorm:"primaryKey"`
+	ProjectId     uint   `gorm:"index"`
+	BlueprintId   uint64
+	Runs          int64
+	ME            int32
+	PE            int32
+	Selected      bool
+	Decryptor     uint64
+	Structure     int32
+	Rig           int32
+	Security      int32
+	SystemId      uint64
+	SystemName    string
+	Tax           float64
+	Owned         bool
+	CharacterId   uint64 // Character chosen to run the jobs, when project was saved
+	CharacterName string
+}
+
+type ProjectBlueprintCopy struct {
+	ID            uint64 `gorm:"primaryKey"`
+	ProjectPlanId uint64 `gorm:"index"`
+	ME            int32
+	PE            int32
+	Runs          int64
+	Quantity      int64
+}
+
+const (
+	ProjectRoleOwner  = "owner"
+	ProjectRoleEditor = "editor"
+	ProjectRoleViewer = "viewer"
+)
+
+type ProjectMember struct {
+	ID            uint64 `gorm:"primaryKey"`
+	ProjectId     uint   `gorm:"index"`
+	MemberId      uint64 `gorm:"index"` // Character or corporation
+	MemberName    string
+	IsCorporation bool
+	Role          string
+}
+
+// Projects owned by or shared with any of given characters or corporations
+func FindProjects(db *gorm.DB, characterIDs []uint64, corporationIDs []uint64) []Project {
+	var result []Project
+
+	members := db.Model(&ProjectMember{}).
+		Select("project_id").
+		Where("(member_id in ? and is_corporation = ?) or (member_id in ? and is_corporation = ?)", characterIDs, false, corporationIDs, true)
+
+	db.Where("(owner_id in ? and is_corporation = ?) or (owner_id in ? and is_corporation = ?) or id in (?)", characterIDs, false, corporationIDs, true, members).
+		Order("archived, name").
+		Find(&result)
+
+	return result
 }
 
 type SystemCostIndices struct {
@@ -157,6 +427,29 @@ type SystemCostIndices struct {
 	Reaction      float32
 }
 
+type AdjustedPrice struct {
+	ID            uint64 `gorm:"primaryKey"`
+	AdjustedPrice float64
+	AveragePrice  float64
+}
+
+type MarketHub struct {
+	ID          uint64 `gorm:"primaryKey"`
+	Name        string
+	RegionId    uint64
+	StationId   uint64 // 0 means whole region
+	CharacterId uint64 // Character who added the hub, 0 for hubs seeded with database, which can't be removed
+}
+
+type MarketPrice struct {
+	ID        uint64 `gorm:"primaryKey"`
+	HubId     uint64 `gorm:"index:hub_type_idx,unique"`
+	TypeId    uint64 `gorm:"index:hub_type_idx,unique"`
+	Buy       float64
+	Sell      float64
+	UpdatedAt time.Time
+}
+
 type Location struct {
 	gorm.Model
 	CharacterId uint64
@@ -166,15 +459,25 @@ type Location struct {
 	Label       string
 }
 
+var (
+	eveDatabase     *gorm.DB
+	eveDatabaseOnce sync.Once
+)
+
+// Single connection pool shared by all requests, *gorm.DB is safe for concurrent use
 func OpenEveDatabase() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("resources/eve.db"), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
+	eveDatabaseOnce.Do(func() {
+		db, err := gorm.Open(sqlite.Open("resources/eve.db"), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		eveDatabase = db
 	})
-	if err != nil {
-		log.Fatalln(err)
-	}
 
-	return db
+	return eveDatabase
 }
 
 func InitEveDatabase() {
@@ -185,11 +488,40 @@ func InitEveDatabase() {
 	db.AutoMigrate(&EVEBlueprint{})
 	db.AutoMigrate(&EVEMaterial{})
 	db.AutoMigrate(&EVEDecryptor{})
+	db.AutoMigrate(&EVEInventionProduct{})
+	db.AutoMigrate(&EVEActivitySkill{})
+	db.AutoMigrate(&EVEType{})
+	db.AutoMigrate(&EVEGroup{})
+	db.AutoMigrate(&EVECategory{})
+	db.AutoMigrate(&EVEMarketGroup{})
 
 	db.AutoMigrate(&ESIUser{})
 	db.AutoMigrate(&ESICall{})
+	db.AutoMigrate(&CharacterSkill{})
+	db.AutoMigrate(&OwnedBlueprint{})
+	db.AutoMigrate(&Asset{})
+	db.AutoMigrate(&IndustryJob{})
+	db.AutoMigrate(&Project{})
+	db.AutoMigrate(&ProjectPlan{})
+	db.AutoMigrate(&ProjectBlueprintCopy{})
+	db.AutoMigrate(&ProjectMember{})
 	db.AutoMigrate(&Location{})
 	db.AutoMigrate(&SystemCostIndices{})
+	db.AutoMigrate(&AdjustedPrice{})
+	db.AutoMigrate(&MarketHub{})
+	db.AutoMigrate(&MarketPrice{})
+
+	var hubs int64
+	db.Model(&MarketHub{}).Count(&hubs)
+	if hubs == 0 {
+		db.Create(&[]MarketHub{
+			{Name: "Jita", RegionId: 10000002, StationId: 60003760},
+			{Name: "Amarr", RegionId: 10000043, StationId: 60008494},
+			{Name: "Dodixie", RegionId: 10000032, StationId: 60011866},
+			{Name: "Rens", RegionId: 10000030, StationId: 60004588},
+			{Name: "Hek", RegionId: 10000042, StationId: 60005686},
+		})
+	}
 
 	gob.Register(ESICall{})
 	gob.Register([]ESICall{})
@@ -200,6 +532,9 @@ func InitEveDatabase() {
 	gob.Register(Location{})
 	gob.Register([]Location{})
 
+	gob.Register(OwnedBlueprint{})
+	gob.Register([]OwnedBlueprint{})
+
 	gob.Register(EVERegion{})
 	gob.Register([]EVERegion{})
 
@@ -217,6 +552,15 @@ func InitEveDatabase() {
 
 	gob.Register(EVEDecryptor{})
 	gob.Register([]EVEDecryptor{})
+
+	gob.Register(EVEInventionProduct{})
+	gob.Register([]EVEInventionProduct{})
+
+	gob.Register(EVEActivitySkill{})
+	gob.Register([]EVEActivitySkill{})
+
+	gob.Register(MarketHub{})
+	gob.Register([]MarketHub{})
 }
 
 func CookDatabase(path string) {
@@ -286,7 +630,8 @@ func CookDatabase(path string) {
 			log.Fatalln(err)
 		}
 
-		db.Exec("DELETE FROM eve_stations")
+		// Structures found in assets are kept
+		db.Where("npc = ?", true).Delete(&EVEStation{})
 		var stations []EVEStation
 
 		for rows.Next() {
@@ -306,6 +651,124 @@ func CookDatabase(path string) {
 		log.Printf("EVEStations: Added %d records\n", len(stations))
 	}
 
+	{
+		rows, err := source.Raw(`
+			select it.typeID,
+				it.groupID,
+				coalesce(it.marketGroupID, 0),
+				it.typeName,
+				coalesce(it.volume, 0),
+				coalesce((select iv.volume from invVolumes iv where iv.typeID = it.typeID), it.volume, 0) as packaged_volume,
+				coalesce(it.basePrice, 0),
+				it.published = '1'
+			from invTypes it
+		`).Rows()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		db.Exec("DELETE FROM eve_types")
+		var types []EVEType
+
+		for rows.Next() {
+			var item EVEType
+			rows.Scan(&item.ID,
+				&item.GroupId,
+				&item.MarketGroupId,
+				&item.Name,
+				&item.Volume,
+				&item.PackagedVolume,
+				&item.BasePrice,
+				&item.Published,
+			)
+
+			types = append(types, item)
+		}
+		rows.Close()
+
+		result := db.CreateInBatches(&types, 1000)
+		if result.Error != nil {
+			log.Println(result.Error)
+		}
+
+		log.Printf("EVETypes: Added %d records\n", len(types))
+	}
+
+	{
+		rows, err := source.Raw("SELECT groupID, categoryID, groupName from invGroups").Rows()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		db.Exec("DELETE FROM eve_groups")
+		var groups []EVEGroup
+
+		for rows.Next() {
+			var group EVEGroup
+			rows.Scan(&group.ID, &group.CategoryId, &group.Name)
+
+			groups = append(groups, group)
+		}
+		rows.Close()
+
+		result := db.CreateInBatches(&groups, 1000)
+		if result.Error != nil {
+			log.Println(result.Error)
+		}
+
+		log.Printf("EVEGroups: Added %d records\n", len(groups))
+	}
+
+	{
+		rows, err := source.Raw("SELECT categoryID, categoryName from invCategories").Rows()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		db.Exec("DELETE FROM eve_categories")
+		var categories []EVECategory
+
+		for rows.Next() {
+			var category EVECategory
+			rows.Scan(&category.ID, &category.Name)
+
+			categories = append(categories, category)
+		}
+		rows.Close()
+
+		result := db.CreateInBatches(&categories, 1000)
+		if result.Error != nil {
+			log.Println(result.Error)
+		}
+
+		log.Printf("EVECategories: Added %d records\n", len(categories))
+	}
+
+	{
+		rows, err := source.Raw("SELECT marketGroupID, coalesce(parentGroupID, 0), marketGroupName from invMarketGroups").Rows()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		db.Exec("DELETE FROM eve_market_groups")
+		var marketGroups []EVEMarketGroup
+
+		for rows.Next() {
+			var marketGroup EVEMarketGroup
+			rows.Scan(&marketGroup.ID, &marketGroup.ParentGroupId, &marketGroup.Name)
+
+			marketGroups = append(marketGroups, marketGroup)
+		}
+		rows.Close()
+
+		result := db.CreateInBatches(&marketGroups, 1000)
+		if result.Error != nil {
+			log.Println(result.Error)
+		}
+
+		log.Printf("EVEMarketGroups: Added %d records\n", len(marketGroups))
+	}
+
 	{
 		rows, err := source.Raw(`
 			select distinct ia.typeID as id,
@@ -400,6 +863,83 @@ func CookDatabase(path string) {
 		log.Printf("EVEMaterial: Added %d records\n", len(materials))
 	}
 
+	{
+		rows, err := source.Raw(`
+			select iap.typeID as blueprint_id,
+				it.typeName as blueprint_name,
+				iap.productTypeID as product_blueprint_id,
+				iap.quantity as runs,
+				coalesce((select probability from industryActivityProbabilities where typeID = iap.typeID and activityID = 8 and productTypeID = iap.productTypeID), 0) as probability
+			from industryActivityProducts iap left join invTypes it on (iap.typeID = it.typeID) where iap.activityID = 8 and it.published = '1'
+		`).Rows()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		db.Exec("DELETE FROM eve_invention_products")
+		var inventions []EVEInventionProduct
+
+		for rows.Next() {
+			var invention EVEInventionProduct
+			rows.Scan(&invention.BlueprintId,
+				&invention.BlueprintName,
+				&invention.ProductBlueprintId,
+				&invention.Runs,
+				&invention.Probability,
+			)
+
+			inventions = append(inventions, invention)
+		}
+		rows.Close()
+
+		result := db.CreateInBatches(&inventions, 1000)
+		if result.Error != nil {
+			log.Println(result.Error)
+		}
+
+		log.Printf("EVEInventionProducts: Added %d records\n", len(inventions))
+	}
+
+	{
+		rows, err := source.Raw(`
+			select ias.typeID as blueprint_id,
+				ias.activityID as activity_id,
+				ias.skillID as skill_id,
+				it.typeName as skill_name,
+				it.groupID as skill_group_id,
+				ias.level
+			from industryActivitySkills ias left join invTypes it on (ias.skillID = it.typeID)
+			where ias.typeID in (select typeID from invTypes where published = '1')
+		`).Rows()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		db.Exec("DELETE FROM eve_activity_skills")
+		var skills []EVEActivitySkill
+
+		for rows.Next() {
+			var skill EVEActivitySkill
+			rows.Scan(&skill.BlueprintId,
+				&skill.ActivityId,
+				&skill.SkillId,
+				&skill.SkillName,
+				&skill.SkillGroupId,
+				&skill.Level,
+			)
+
+			skills = append(skills, skill)
+		}
+		rows.Close()
+
+		result := db.CreateInBatches(&skills, 1000)
+		if result.Error != nil {
+			log.Println(result.Error)
+		}
+
+		log.Printf("EVEActivitySkills: Added %d records\n", len(skills))
+	}
+
 	{
 		db.Exec("DELETE FROM eve_decryptors")
 		decryptors := []EVEDecryptor{
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ESI adjusted prices and system cost indices, kept in memory until ESI data is updated.
+// Returned maps are shared and must not be modified
+var industryData struct {
+	sync.Mutex
+	adjustedPrices map[uint64]float64
+	costIndices    map[uint64]SystemCostIndices
+}
+
+func AdjustedPrices(db *gorm.DB) map[uint64]float64 {
+	industryData.Lock()
+	defer industryData.Unlock()
+
+	if industryData.adjustedPrices == nil {
+		var prices []AdjustedPrice
+		db.Find(&prices)
+
+		industryData.adjustedPrices = make(map[uint64]float64, len(prices))
+		for _, price := range prices {
+			industryData.adjustedPrices[price.ID] = price.AdjustedPrice
+		}
+	}
+
+	return industryData.adjustedPrices
+}
+
+func CostIndices(db *gorm.DB) map[uint64]SystemCostIndices {
+	industryData.Lock()
+	defer industryData.Unlock()
+
+	if industryData.costIndices == nil {
+		var indices []SystemCostIndices
+		db.Find(&indices)
+
+		industryData.costIndices = make(map[uint64]SystemCostIndices, len(indices))
+		for _, index := range indices {
+			industryData.costIndices[index.ID] = index
+		}
+	}
+
+	return industryData.costIndices
+}
+
+// Called after adjusted prices table is replaced, next call to AdjustedPrices reloads it
+func InvalidateAdjustedPrices() {
+	industryData.Lock()
+	defer industryData.Unlock()
+
+	industryData.adjustedPrices = nil
+}
+
+// Called after cost indices table is replaced, next call to CostIndices reloads it
+func InvalidateCostIndices() {
+	industryData.Lock()
+	defer industryData.Unlock()
+
+	industryData.costIndices = nil
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Static data from the cooked SDE, loaded once and never modified afterwards.
+// Returned slices are shared and must not be modified
+type StaticData struct {
+	blueprints        map[uint64]EVEBlueprint
+	blueprintsByName  map[string]uint64
+	producers         map[uint64]uint64 // Product type ID to blueprint ID
+	producersByName   map[string]uint64 // Product name to blueprint ID
+	materials         map[uint64]map[uint32][]EVEMaterial
+	skills            map[uint64]map[uint32][]EVEActivitySkill
+	inventions        map[uint64]EVEInventionProduct // By invented blueprint ID, most probable source
+	decryptors        map[uint64]EVEDecryptor
+	decryptorsOrdered []EVEDecryptor
+	systems           map[uint64]EVESystem
+	systemsByName     map[string]uint64
+	types             map[uint64]EVETypeInfo
+}
+
+var (
+	staticData     *StaticData
+	staticDataOnce sync.Once
+)
+
+func LoadStaticData() *StaticData {
+	staticDataOnce.Do(func() {
+		started := time.Now()
+		staticData = newStaticData()
+		log.Printf("Static data loaded in %s\n", time.Since(started))
+	})
+
+	return staticData
+}
+
+func newStaticData() *StaticData {
+	db := OpenEveDatabase()
+
+	result := &StaticData{
+		blueprints:       make(map[uint64]EVEBlueprint),
+		blueprintsByName: make(map[string]uint64),
+		producers:        make(map[uint64]uint64),
+		producersByName:  make(map[string]uint64),
+		materials:        make(map[uint64]map[uint32][]EVEMaterial),
+		skills:           make(map[uint64]map[uint32][]EVEActivitySkill),
+		inventions:       make(map[uint64]EVEInventionProduct),
+		decryptors:       make(map[uint64]EVEDecryptor),
+		systems:          make(map[uint64]EVESystem),
+		systemsByName:    make(map[string]uint64),
+		types:            make(map[uint64]EVETypeInfo),
+	}
+
+	var blueprints []EVEBlueprint
+	db.Order("id").Find(&blueprints)
+
+	// First blueprint wins, same as taking it by product from database
+	for _, blueprint := range blueprints {
+		result.blueprints[blueprint.ID] = blueprint
+
+		if _, exists := result.blueprintsByName[blueprint.Name]; !exists {
+			result.blueprintsByName[blueprint.Name] = blueprint.ID
+		}
+
+		if blueprint.ManufacturingProductId > 0 {
+			if _, exists := result.producers[blueprint.ManufacturingProductId]; !exists {
+				result.producers[blueprint.ManufacturingProductId] = blueprint.ID
+			}
+
+			if _, exists := result.producersByName[blueprint.ManufacturingProductName]; !exists {
+				result.producersByName[blueprint.ManufacturingProductName] = blueprint.ID
+			}
+		}
+	}
+
+	var materials []EVEMaterial
+	db.Order("blueprint_id, activity_id, material_id").Find(&materials)
+
+	for _, material := range materials {
+		if result.materials[material.BlueprintId] == nil {
+			result.materials[material.BlueprintId] = make(map[uint32][]EVEMaterial)
+		}
+
+		result.materials[material.BlueprintId][material.ActivityId] = append(result.materials[material.BlueprintId][material.ActivityId], material)
+	}
+
+	var skills []EVEActivitySkill
+	db.Order("blueprint_id, activity_id, skill_id").Find(&skills)
+
+	for _, skill := range skills {
+		if result.skills[skill.BlueprintId] == nil {
+			result.skills[skill.BlueprintId] = make(map[uint32][]EVEActivitySkill)
+		}
+
+		result.skills[skill.BlueprintId][skill.ActivityId] = append(result.skills[skill.BlueprintId][skill.ActivityId], skill)
+	}
+
+	var inventions []EVEInventionProduct
+	db.Order("probability desc, id").Find(&inventions)
+
+	for _, invention := range inventions {
+		if _, exists := result.inventions[invention.ProductBlueprintId]; !exists {
+			result.inventions[invention.ProductBlueprintId] = invention
+		}
+	}
+
+	db.Order("id").Find(&result.decryptorsOrdered)
+	for _, decryptor := range result.decryptorsOrdered {
+		result.decryptors[decryptor.ID] = decryptor
+	}
+
+	var systems []EVESystem
+	db.Order("id").Find(&systems)
+
+	for _, system := range systems {
+		result.systems[system.ID] = system
+		result.systemsByName[system.SystemName] = system.ID
+	}
+
+	var types []EVETypeInfo
+	db.Model(&EVEType{}).
+		Select("eve_types.*, eve_groups.name as group_name, eve_groups.category_id, eve_categories.name as category_name").
+		Joins("left outer join eve_groups on eve_types.group_id = eve_groups.id").
+		Joins("left outer join eve_categories on eve_groups.category_id = eve_categories.id").
+		Scan(&types)
+
+	for _, info := range types {
+		result.types[info.ID] = info
+	}
+
+	return result
+}
+
+func (s *StaticData) Blueprint(blueprintID uint64) (EVEBlueprint, bool) {
+	blueprint, exists := s.blueprints[blueprintID]
+	return blueprint, exists
+}
+
+func (s *StaticData) BlueprintByName(name string) (EVEBlueprint, bool) {
+	if blueprintID, exists := s.blueprintsByName[name]; exists {
+		return s.Blueprint(blueprintID)
+	}
+
+	return EVEBlueprint{}, false
+}
+
+// Blueprint or reaction formula producing given item
+func (s *StaticData) BlueprintForProduct(productID uint64) (EVEBlueprint, bool) {
+	if blueprintID, exists := s.producers[productID]; exists {
+		return s.Blueprint(blueprintID)
+	}
+
+	return EVEBlueprint{}, false
+}
+
+func (s *StaticData) BlueprintForProductName(name string) (EVEBlueprint, bool) {
+	if blueprintID, exists := s.producersByName[name]; exists {
+		return s.Blueprint(blueprintID)
+	}
+
+	return EVEBlueprint{}, false
+}
+
+func (s *StaticData) Materials(blueprintID uint64, activityID uint32) []EVEMaterial {
+	return s.materials[blueprintID][activityID]
+}
+
+func (s *StaticData) Skills(blueprintID uint64, activityID uint32) []EVEActivitySkill {
+	return s.skills[blueprintID][activityID]
+}
+
+// Most probable invention of given T2 blueprint
+func (s *StaticData) InventionFor(blueprintID uint64) (EVEInventionProduct, bool) {
+	invention, exists := s.inventions[blueprintID]
+	return invention, exists
+}
+
+func (s *StaticData) Decryptor(decryptorID uint64) (EVEDecryptor, bool) {
+	decryptor, exists := s.decryptors[decryptorID]
+	return decryptor, exists
+}
+
+func (s *StaticData) Decryptors() []EVEDecryptor {
+	return s.decryptorsOrdered
+}
+
+func (s *StaticData) System(systemID uint64) (EVESystem, bool) {
+	system, exists := s.systems[systemID]
+	return system, exists
+}
+
+func (s *StaticData) SystemByName(name string) (EVESystem, bool) {
+	if systemID, exists := s.systemsByName[name]; exists {
+		return s.System(systemID)
+	}
+
+	return EVESystem{}, false
+}
+
+// Type with its group and category names, also used to resolve name of any type ID
+func (s *StaticData) TypeInfo(typeID uint64) (EVETypeInfo, bool) {
+	info, exists := s.types[typeID]
+	return info, exists
+}
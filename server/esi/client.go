@@ -10,6 +10,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +20,9 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// Upwell structure IDs start here, NPC stations and solar systems have lower IDs
+const firstStructureID = 1000000000000
+
 type ESIClient struct {
 	user db.ESIUser
 	db   *gorm.DB
@@ -39,6 +43,7 @@ type esiResponse struct {
 	validUntil time.Time
 	is_valid   bool
 	cached     bool
+	pages      int
 }
 
 type esiCostIndex struct {
@@ -51,6 +56,76 @@ type EsiCostIndices struct {
 	SolarSystemID uint64         `json:"solar_system_id"`
 }
 
+type esiMarketPrice struct {
+	TypeID        uint64  `json:"type_id"`
+	AdjustedPrice float64 `json:"adjusted_price"`
+	AveragePrice  float64 `json:"average_price"`
+}
+
+type EsiMarketOrder struct {
+	OrderID      uint64  `json:"order_id"`
+	TypeID       uint64  `json:"type_id"`
+	LocationID   uint64  `json:"location_id"`
+	IsBuyOrder   bool    `json:"is_buy_order"`
+	Price        float64 `json:"price"`
+	VolumeRemain int64   `json:"volume_remain"`
+}
+
+type EsiSkill struct {
+	SkillID            uint64 `json:"skill_id"`
+	ActiveSkillLevel   int32  `json:"active_skill_level"`
+	TrainedSkillLevel  int32  `json:"trained_skill_level"`
+	SkillpointsInSkill int64  `json:"skillpoints_in_skill"`
+}
+
+type EsiSkills struct {
+	Skills  []EsiSkill `json:"skills"`
+	TotalSP int64      `json:"total_sp"`
+}
+
+type EsiBlueprint struct {
+	ItemID             uint64 `json:"item_id"`
+	LocationFlag       string `json:"location_flag"`
+	LocationID         uint64 `json:"location_id"`
+	MaterialEfficiency int32  `json:"material_efficiency"`
+	Quantity           int64  `json:"quantity"` // -1 for original, -2 for copy, positive for stack of originals
+	Runs               int64  `json:"runs"`     // -1 for original
+	TimeEfficiency     int32  `json:"time_efficiency"`
+	TypeID             uint64 `json:"type_id"`
+}
+
+type EsiAsset struct {
+	IsBlueprintCopy bool   `json:"is_blueprint_copy"`
+	IsSingleton     bool   `json:"is_singleton"`
+	ItemID          uint64 `json:"item_id"`
+	LocationFlag    string `json:"location_flag"`
+	LocationID      uint64 `json:"location_id"`
+	LocationType    string `json:"location_type"`
+	Quantity        int64  `json:"quantity"`
+	TypeID          uint64 `json:"type_id"`
+}
+
+type EsiIndustryJob struct {
+	ActivityID      int32     `json:"activity_id"`
+	BlueprintID     uint64    `json:"blueprint_id"`
+	BlueprintTypeID uint64    `json:"blueprint_type_id"`
+	EndDate         time.Time `json:"end_date"`
+	FacilityID      uint64    `json:"facility_id"`
+	InstallerID     uint64    `json:"installer_id"`
+	JobID           uint64    `json:"job_id"`
+	ProductTypeID   uint64    `json:"product_type_id"`
+	Runs            int64     `json:"runs"`
+	StartDate       time.Time `json:"start_date"`
+	Status          string    `json:"status"`
+}
+
+type EsiStructure struct {
+	Name          string `json:"name"`
+	OwnerID       uint64 `json:"owner_id"`
+	SolarSystemID uint64 `json:"solar_system_id"`
+	TypeID        uint64 `json:"type_id"`
+}
+
 type EsiCharacterInfo struct {
 	CharacterID    uint64
 	AllianceID     int32   `json:"alliance_id"`
@@ -69,11 +144,53 @@ func NewESIClient(db *gorm.DB, user db.ESIUser) ESIClient {
 	return result
 }
 
-func (c *ESIClient) ListSkills() {
-	requestParams := url.Values{}
+// Second value is true when skills haven't changed since last request
+func (c *ESIClient) ListSkills() ([]EsiSkill, bool, error) {
+	response := c.makeRequest(http.MethodGet, fmt.Sprintf("/latest/characters/%d/skills/", c.user.ID), url.Values{})
+	if response.error != nil {
+		return nil, false, response.error
+	}
+
+	var result EsiSkills
+	json.Unmarshal([]byte(response.body), &result)
 
-	response := c.makeRequest(http.MethodGet, fmt.Sprintf("/latest/characters/%d/skills/", c.user.ID), requestParams)
-	log.Println(response)
+	return result.Skills, response.cached, nil
+}
+
+func (c *ESIClient) UpdateSkills() error {
+	esi_result, cached, err := c.ListSkills()
+	if err != nil {
+		return err
+	}
+
+	if cached {
+		return nil
+	}
+
+	c.db.Delete(&db.CharacterSkill{}, "character_id = ?", c.user.ID)
+	skills := make([]db.CharacterSkill, 0, len(esi_result))
+
+	for _, skill := range esi_result {
+		skills = append(skills, db.CharacterSkill{
+			CharacterId:  c.user.ID,
+			SkillId:      skill.SkillID,
+			Level:        skill.ActiveSkillLevel,
+			TrainedLevel: skill.TrainedSkillLevel,
+			SkillPoints:  skill.SkillpointsInSkill,
+		})
+	}
+
+	if len(skills) == 0 {
+		return nil
+	}
+
+	result := c.db.CreateInBatches(&skills, 1000)
+	if result.Error != nil {
+		log.Println(result.Error)
+		return result.Error
+	}
+
+	return nil
 }
 
 func (c *ESIClient) ListIndustrySystems() ([]EsiCostIndices, error) {
@@ -100,6 +217,19 @@ func (c *ESIClient) GetCharacterInfo(characterID uint64) (EsiCharacterInfo, erro
 	return result, nil
 }
 
+// Character needs docking access to the structure
+func (c *ESIClient) GetStructure(structureID uint64) (EsiStructure, error) {
+	response := c.makeRequest(http.MethodGet, fmt.Sprintf("/latest/universe/structures/%d/", structureID), url.Values{})
+	if response.error != nil {
+		return EsiStructure{}, response.error
+	}
+
+	var result EsiStructure
+	json.Unmarshal([]byte(response.body), &result)
+
+	return result, nil
+}
+
 func (c *ESIClient) UpdateSystemCostIndices() error {
 	response := c.makeRequest(http.MethodGet, "/latest/industry/systems/", url.Values{})
 	if response.error != nil {
@@ -142,6 +272,338 @@ func (c *ESIClient) UpdateSystemCostIndices() error {
 	}
 
 	result := c.db.CreateInBatches(&indices, 1000)
+	db.InvalidateCostIndices()
+	if result.Error != nil {
+		log.Println(result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *ESIClient) UpdateAdjustedPrices() error {
+	response := c.makeRequest(http.MethodGet, "/latest/markets/prices/", url.Values{})
+	if response.error != nil {
+		return response.error
+	}
+
+	if response.cached {
+		return nil
+	}
+
+	var esi_result []esiMarketPrice
+	json.Unmarshal([]byte(response.body), &esi_result)
+
+	c.db.Exec("DELETE FROM adjusted_prices")
+	prices := make([]db.AdjustedPrice, 0, len(esi_result))
+
+	for _, price := range esi_result {
+		prices = append(prices, db.AdjustedPrice{
+			ID:            price.TypeID,
+			AdjustedPrice: price.AdjustedPrice,
+			AveragePrice:  price.AveragePrice,
+		})
+	}
+
+	result := c.db.CreateInBatches(&prices, 1000)
+	db.InvalidateAdjustedPrices()
+	if result.Error != nil {
+		log.Println(result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+func (c *ESIClient) ListMarketOrders(regionID uint64, typeID uint64) ([]EsiMarketOrder, error) {
+	requestParams := url.Values{}
+	requestParams.Add("order_type", "all")
+	requestParams.Add("type_id", fmt.Sprint(typeID))
+
+	result, _, err := listPaged[EsiMarketOrder](c, fmt.Sprintf("/latest/markets/%d/orders/", regionID), requestParams)
+	return result, err
+}
+
+// Items of character endpoint, followed by items of the same endpoint of character's corporation.
+// Corporation endpoints need roles the character may not have, so failure there is only logged
+func updateCharacterAndCorporation[T any](c *ESIClient, endpoint string, params url.Values, save func(ownerID uint64, isCorporation bool, items []T) error) error {
+	items, cached, err := listPaged[T](c, fmt.Sprintf("/latest/characters/%d/%s/", c.user.ID, endpoint), params)
+	if err != nil {
+		return err
+	}
+
+	if !cached {
+		err = save(c.user.ID, false, items)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.user.CorporationId == 0 {
+		return nil
+	}
+
+	items, cached, err = listPaged[T](c, fmt.Sprintf("/latest/corporations/%d/%s/", c.user.CorporationId, endpoint), params)
+	if err != nil {
+		log.Println("Corporation", endpoint, c.user.CorporationId, err)
+		return nil
+	}
+
+	if !cached {
+		return save(c.user.CorporationId, true, items)
+	}
+
+	return nil
+}
+
+// Corporation blueprints need director role
+func (c *ESIClient) UpdateBlueprints() error {
+	return updateCharacterAndCorporation(c, "blueprints", url.Values{}, c.saveBlueprints)
+}
+
+func (c *ESIClient) saveBlueprints(ownerID uint64, isCorporation bool, esi_result []EsiBlueprint) error {
+	c.db.Delete(&db.OwnedBlueprint{}, "owner_id = ? and is_corporation = ?", ownerID, isCorporation)
+	blueprints := make([]db.OwnedBlueprint, 0, len(esi_result))
+
+	for _, blueprint := range esi_result {
+		blueprints = append(blueprints, db.OwnedBlueprint{
+			ItemId:        blueprint.ItemID,
+			OwnerId:       ownerID,
+			IsCorporation: isCorporation,
+			TypeId:        blueprint.TypeID,
+			LocationId:    blueprint.LocationID,
+			LocationFlag:  blueprint.LocationFlag,
+			ME:            blueprint.MaterialEfficiency,
+			TE:            blueprint.TimeEfficiency,
+			Runs:          blueprint.Runs,
+			Quantity:      blueprint.Quantity,
+			IsCopy:        blueprint.Quantity == -2,
+		})
+	}
+
+	if len(blueprints) == 0 {
+		return nil
+	}
+
+	result := c.db.CreateInBatches(&blueprints, 1000)
+	if result.Error != nil {
+		log.Println(result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// Corporation assets need director role
+func (c *ESIClient) UpdateAssets() error {
+	return updateCharacterAndCorporation(c, "assets", url.Values{}, c.saveAssets)
+}
+
+// Upwell structures are not in cooked database, they are resolved through ESI once and stored
+// with NPC stations, so stock in them can be picked as location. Unknown systems are cached as 0
+func (c *ESIClient) stationSystem(stationID uint64, stationSystems map[uint64]uint64) uint64 {
+	if systemID, exists := stationSystems[stationID]; exists || stationID < firstStructureID {
+		return systemID
+	}
+
+	stationSystems[stationID] = 0
+
+	structure, err := c.GetStructure(stationID)
+	if err != nil {
+		log.Println("Structure", stationID, err)
+		return 0
+	}
+
+	stationSystems[stationID] = structure.SolarSystemID
+	c.db.Save(&db.EVEStation{
+		ID:          stationID,
+		SystemId:    structure.SolarSystemID,
+		StationName: structure.Name,
+	})
+
+	return structure.SolarSystemID
+}
+
+func (c *ESIClient) saveAssets(ownerID uint64, isCorporation bool, esi_result []EsiAsset) error {
+	c.db.Delete(&db.Asset{}, "owner_id = ? and is_corporation = ?", ownerID, isCorporation)
+	assets := make([]db.Asset, 0, len(esi_result))
+
+	items := make(map[uint64]EsiAsset, len(esi_result))
+	for _, asset := range esi_result {
+		items[asset.ItemID] = asset
+	}
+
+	var stations []db.EVEStation
+	c.db.Find(&stations)
+
+	stationSystems := make(map[uint64]uint64, len(stations))
+	for _, station := range stations {
+		stationSystems[station.ID] = station.SystemId
+	}
+
+	for _, asset := range esi_result {
+		saved := db.Asset{
+			ItemId:        asset.ItemID,
+			OwnerId:       ownerID,
+			IsCorporation: isCorporation,
+			TypeId:        asset.TypeID,
+			LocationId:    asset.LocationID,
+			LocationFlag:  asset.LocationFlag,
+			Quantity:      asset.Quantity,
+			IsSingleton:   asset.IsSingleton,
+			IsCopy:        asset.IsBlueprintCopy,
+		}
+
+		// Walk up through containers and offices, top-most hangar division wins
+		root := asset
+		for {
+			if strings.HasPrefix(root.LocationFlag, "CorpSAG") {
+				division, _ := strconv.ParseUint(strings.TrimPrefix(root.LocationFlag, "CorpSAG"), 10, 32)
+				saved.Hangar = uint32(division)
+			}
+
+			parent, exists := items[root.LocationID]
+			if !exists {
+				break
+			}
+
+			root = parent
+		}
+
+		if root.LocationType == "solar_system" {
+			saved.SystemId = root.LocationID
+		} else {
+			saved.StationId = root.LocationID
+			saved.SystemId = c.stationSystem(root.LocationID, stationSystems)
+		}
+
+		assets = append(assets, saved)
+	}
+
+	if len(assets) == 0 {
+		return nil
+	}
+
+	result := c.db.CreateInBatches(&assets, 1000)
+	if result.Error != nil {
+		log.Println(result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// Completed jobs are included, corporation jobs need factory manager role
+func (c *ESIClient) UpdateIndustryJobs() error {
+	requestParams := url.Values{}
+	requestParams.Add("include_completed", "true")
+
+	return updateCharacterAndCorporation(c, "industry/jobs", requestParams, c.saveIndustryJobs)
+}
+
+func (c *ESIClient) saveIndustryJobs(ownerID uint64, isCorporation bool, esi_result []EsiIndustryJob) error {
+	c.db.Delete(&db.IndustryJob{}, "owner_id = ? and is_corporation = ?", ownerID, isCorporation)
+	jobs := make([]db.IndustryJob, 0, len(esi_result))
+
+	blueprintIDs := make([]uint64, 0, len(esi_result))
+	for _, job := range esi_result {
+		blueprintIDs = append(blueprintIDs, job.BlueprintTypeID)
+	}
+
+	var blueprints []db.EVEBlueprint
+	c.db.Where("id in ?", blueprintIDs).Find(&blueprints)
+
+	blueprintsByID := make(map[uint64]db.EVEBlueprint, len(blueprints))
+	for _, blueprint := range blueprints {
+		blueprintsByID[blueprint.ID] = blueprint
+	}
+
+	for _, job := range esi_result {
+		saved := db.IndustryJob{
+			JobId:           job.JobID,
+			OwnerId:         ownerID,
+			IsCorporation:   isCorporation,
+			InstallerId:     job.InstallerID,
+			ActivityId:      job.ActivityID,
+			BlueprintId:     job.BlueprintID,
+			BlueprintTypeId: job.BlueprintTypeID,
+			BlueprintName:   blueprintsByID[job.BlueprintTypeID].Name,
+			ProductTypeId:   job.ProductTypeID,
+			Runs:            job.Runs,
+			Status:          job.Status,
+			FacilityId:      job.FacilityID,
+			StartDate:       job.StartDate,
+			EndDate:         job.EndDate,
+		}
+
+		if blueprint, exists := blueprintsByID[job.BlueprintTypeID]; exists && blueprint.ManufacturingProductId == job.ProductTypeID {
+			saved.ProductName = blueprint.ManufacturingProductName
+		}
+
+		jobs = append(jobs, saved)
+	}
+
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	result := c.db.CreateInBatches(&jobs, 1000)
+	if result.Error != nil {
+		log.Println(result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// Stores corporation of the character, it is needed for corporation endpoints
+func (c *ESIClient) UpdateCharacterInfo() error {
+	info, err := c.GetCharacterInfo(c.user.ID)
+	if err != nil {
+		return err
+	}
+
+	c.user.CorporationId = uint64(info.CorporationID)
+	return c.db.Model(&c.user).Update("corporation_id", c.user.CorporationId).Error
+}
+
+func (c *ESIClient) UpdateMarketPrices(hub db.MarketHub, typeIDs []uint64) error {
+	prices := make([]db.MarketPrice, 0, len(typeIDs))
+
+	for _, typeID := range typeIDs {
+		orders, err := c.ListMarketOrders(hub.RegionId, typeID)
+		if err != nil {
+			return err
+		}
+
+		price := db.MarketPrice{
+			HubId:  hub.ID,
+			TypeId: typeID,
+		}
+
+		for _, order := range orders {
+			if hub.StationId > 0 && order.LocationID != hub.StationId {
+				continue
+			}
+
+			if order.IsBuyOrder && order.Price > price.Buy {
+				price.Buy = order.Price
+			} else if !order.IsBuyOrder && (price.Sell == 0 || order.Price < price.Sell) {
+				price.Sell = order.Price
+			}
+		}
+
+		prices = append(prices, price)
+	}
+
+	if len(prices) == 0 {
+		return nil
+	}
+
+	result := c.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hub_id"}, {Name: "type_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"buy", "sell", "updated_at"}),
+	}).CreateInBatches(&prices, 1000)
 	if result.Error != nil {
 		log.Println(result.Error)
 		return result.Error
@@ -174,6 +636,7 @@ func (c *ESIClient) fetchFromCache(method string, url string, params string) *es
 		etag:       cached.Etag,
 		is_valid:   is_valid,
 		cached:     true,
+		pages:      cached.Pages,
 	}
 }
 
@@ -181,14 +644,58 @@ func (c *ESIClient) saveToCache(method string, url string, params string, respon
 
 	c.db.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "url"}, {Name: "params"}},
-		DoUpdates: clause.AssignmentColumns([]string{"response", "valid_until", "etag"}),
+		DoUpdates: clause.AssignmentColumns([]string{"response", "valid_until", "etag", "pages"}),
 	}).Create(&db.ESICall{
 		URL:        url,
 		Params:     params,
 		Response:   response.body,
 		ValidUntil: response.validUntil,
 		Etag:       response.etag,
+		Pages:      response.pages,
+	})
+}
+
+// Fetches all pages, second value is true when none of them has changed
+func (c *ESIClient) makePagedRequest(uri string, params url.Values, handle func(body string)) (bool, error) {
+	cached := true
+
+	for page := 1; ; page++ {
+		requestParams := url.Values{}
+		for key, values := range params {
+			requestParams[key] = values
+		}
+		requestParams.Set("page", fmt.Sprint(page))
+
+		response := c.makeRequest(http.MethodGet, uri, requestParams)
+		if response.error != nil {
+			return false, response.error
+		}
+
+		cached = cached && response.cached
+		handle(response.body)
+
+		if page >= response.pages {
+			break
+		}
+	}
+
+	return cached, nil
+}
+
+// Collects items from all pages, second value is true when none of them has changed
+func listPaged[T any](c *ESIClient, uri string, params url.Values) ([]T, bool, error) {
+	result := make([]T, 0)
+
+	cached, err := c.makePagedRequest(uri, params, func(body string) {
+		var items []T
+		json.Unmarshal([]byte(body), &items)
+		result = append(result, items...)
 	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return result, cached, nil
 }
 
 func (c *ESIClient) makeRequest(method string, uri string, params url.Values) esiResponse {
@@ -274,6 +781,13 @@ func (c *ESIClient) makeRequest(method string, uri string, params url.Values) es
 	result.status = response.StatusCode
 	result.etag = response.Header.Get("ETag")
 	result.validUntil = expires
+	result.pages = 1
+
+	if pages, err := strconv.Atoi(response.Header.Get("X-Pages")); err == nil {
+		result.pages = pages
+	} else if response.StatusCode == 304 && maybe_cached != nil {
+		result.pages = maybe_cached.pages
+	}
 
 	c.saveToCache(method, uri, paramsCacheKey, result)
 
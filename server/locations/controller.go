@@ -2,6 +2,7 @@ package locations
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -62,23 +63,53 @@ func listStationsHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-func listLocationsHandler(c *gin.Context) {
-	type location struct {
-		db.Location
-		SystemName     *string
-		StationName    *string
-		SecurityStatus float32
+type LocationInfo struct {
+	db.Location
+	SystemName     *string
+	StationName    *string
+	SecurityStatus float32
+}
+
+func (l LocationInfo) Name() string {
+	name := "(Whole universe)"
+	if l.StationName != nil {
+		name = *l.StationName
+	} else if l.SystemName != nil {
+		name = *l.SystemName + " - (Whole system)"
 	}
 
-	var locations []location
+	if l.Hangar > 0 {
+		name += fmt.Sprintf(" - Hangar %d", l.Hangar)
+	}
 
-	evedb := db.OpenEveDatabase()
-	evedb.Model(&db.Location{}).
+	if len(l.Label) > 0 {
+		name += " (" + l.Label + ")"
+	}
+
+	return name
+}
+
+func locationsQuery(evedb *gorm.DB) *gorm.DB {
+	return evedb.Model(&db.Location{}).
 		Select("locations.*, eve_systems.system_name, eve_stations.station_name, eve_systems.security_status").
 		Joins("left outer join eve_systems on locations.system_id = eve_systems.id").
 		Joins("left outer join eve_stations on locations.station_id = eve_stations.id").
-		Order("id").
-		Scan(&locations)
+		Order("locations.id")
+}
+
+// Locations configured by any of given characters
+func FindLocations(evedb *gorm.DB, characterIDs []uint64) []LocationInfo {
+	var locations []LocationInfo
+	locationsQuery(evedb).Where("locations.character_id in ?", characterIDs).Scan(&locations)
+
+	return locations
+}
+
+func listLocationsHandler(c *gin.Context) {
+	var locations []LocationInfo
+
+	evedb := db.OpenEveDatabase()
+	locationsQuery(evedb).Scan(&locations)
 
 	c.JSON(http.StatusOK, locations)
 }
@@ -88,7 +119,7 @@ func addLocationHandler(c *gin.Context) {
 		SystemName string `form:"system_name"`
 		StationId  uint64 `form:"station_id"`
 		Label      string `form:"label"`
-		Hangar     uint32 `format:"hangar"`
+		Hangar     uint32 `form:"hangar"`
 	}
 
 	form := params{}
@@ -104,6 +135,11 @@ func addLocationHandler(c *gin.Context) {
 
 	var location db.Location
 	location.CharacterId = user.ID
+	location.Label = form.Label
+
+	if form.Hangar <= 7 {
+		location.Hangar = form.Hangar
+	}
 
 	evedb := db.OpenEveDatabase()
 	if len(form.SystemName) > 0 {
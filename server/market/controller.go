@@ -0,0 +1,130 @@
+package market
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgibula/eve-industry/server/db"
+	"github.com/mgibula/eve-industry/server/layout"
+)
+
+func RegisterRoutes(c *gin.Engine) {
+	c.GET("/market/hubs", indexHandler)
+	c.GET("/market/hubs/list", listHubsHandler)
+	c.POST("/market/hubs/add", addHubHandler)
+	c.POST("/market/hubs/remove", removeHubHandler)
+}
+
+func indexHandler(c *gin.Context) {
+	layout.Render(c, "default/market-hubs.tmpl", gin.H{})
+}
+
+func listHubsHandler(c *gin.Context) {
+	type hub struct {
+		db.MarketHub
+		RegionName  *string
+		StationName *string
+		Removable   bool
+	}
+
+	var hubs []hub
+
+	evedb := db.OpenEveDatabase()
+	evedb.Model(&db.MarketHub{}).
+		Select("market_hubs.*, eve_regions.name as region_name, eve_stations.station_name").
+		Joins("left outer join eve_regions on market_hubs.region_id = eve_regions.id").
+		Joins("left outer join eve_stations on market_hubs.station_id = eve_stations.id").
+		Order("id").
+		Scan(&hubs)
+
+	if maybe_user, logged := c.Get("user"); logged {
+		for i := range hubs {
+			hubs[i].Removable = hubs[i].CharacterId > 0 && hubs[i].CharacterId == maybe_user.(db.ESIUser).ID
+		}
+	}
+
+	c.JSON(http.StatusOK, hubs)
+}
+
+func addHubHandler(c *gin.Context) {
+	type params struct {
+		Name       string `form:"name"`
+		SystemName string `form:"system_name"`
+		StationId  uint64 `form:"station_id"`
+	}
+
+	form := params{}
+	c.Bind(&form)
+
+	maybe_user, logged := c.Get("user")
+	if !logged {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	evedb := db.OpenEveDatabase()
+
+	var system db.EVESystem
+	err := evedb.Where("system_name = ?", form.SystemName).Take(&system).Error
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	hub := db.MarketHub{
+		Name:        form.Name,
+		RegionId:    system.RegionId,
+		CharacterId: maybe_user.(db.ESIUser).ID,
+	}
+
+	if form.StationId > 0 {
+		var station db.EVEStation
+		err := evedb.Take(&station, form.StationId).Error
+		if err != nil || station.SystemId != system.ID {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		hub.StationId = station.ID
+	}
+
+	if len(hub.Name) == 0 {
+		hub.Name = system.SystemName
+	}
+
+	evedb.Create(&hub)
+	c.Redirect(http.StatusFound, "/market/hubs")
+}
+
+// Only hubs added by the character can be removed, hubs are shared by all users
+func removeHubHandler(c *gin.Context) {
+	type params struct {
+		HubID uint64 `form:"hub_id"`
+	}
+
+	form := params{}
+	c.Bind(&form)
+
+	maybe_user, logged := c.Get("user")
+	if !logged {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	evedb := db.OpenEveDatabase()
+
+	var hub db.MarketHub
+	if err := evedb.Take(&hub, form.HubID).Error; err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if hub.CharacterId == 0 || hub.CharacterId != maybe_user.(db.ESIUser).ID {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	evedb.Delete(&db.MarketPrice{}, "hub_id = ?", hub.ID)
+	evedb.Delete(&hub)
+
+	c.Redirect(http.StatusFound, "/market/hubs")
+}
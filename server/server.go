@@ -12,12 +12,14 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/mgibula/eve-industry/server/api"
 	"github.com/mgibula/eve-industry/server/calculator"
 	"github.com/mgibula/eve-industry/server/config"
 	"github.com/mgibula/eve-industry/server/db"
 	"github.com/mgibula/eve-industry/server/esi"
 	"github.com/mgibula/eve-industry/server/layout"
 	"github.com/mgibula/eve-industry/server/locations"
+	"github.com/mgibula/eve-industry/server/market"
 	"github.com/mgibula/eve-industry/server/sessions"
 	"github.com/mgibula/eve-industry/server/sso"
 )
@@ -80,6 +82,7 @@ func CreateServer() Server {
 	}
 
 	db.InitEveDatabase()
+	db.LoadStaticData()
 
 	db, err := gorm.Open(sqlite.Open("resources/sessions.db"), &gorm.Config{})
 	if err != nil {
@@ -99,8 +102,10 @@ func CreateServer() Server {
 	result.gin.StaticFile("/favicon.ico", "resources/public/favicon.ico")
 
 	calculator.RegisterRoutes(result.gin)
+	api.RegisterRoutes(result.gin)
 	sso.RegisterRoutes(result.gin)
 	locations.RegisterRoutes(result.gin)
+	market.RegisterRoutes(result.gin)
 	result.gin.GET("/dashboard", IndexController)
 	result.gin.GET("/", IndexController)
 
@@ -111,14 +116,92 @@ func (s *Server) Run(listen string) {
 	s.gin.Run(listen)
 }
 
+// Refreshes ESI data of the character. Returns character reloaded from database, with corporation
+// updated, and names of updates that failed
+func syncCharacter(evedb *gorm.DB, character db.ESIUser) (db.ESIUser, []string) {
+	client := esi.NewESIClient(evedb, character)
+	failed := make([]string, 0)
+
+	// Character info goes first, corporation endpoints use its corporation ID
+	updates := []struct {
+		name   string
+		update func() error
+	}{
+		{"character info", client.UpdateCharacterInfo},
+		{"skills", client.UpdateSkills},
+		{"blueprints", client.UpdateBlueprints},
+		{"assets", client.UpdateAssets},
+		{"industry jobs", client.UpdateIndustryJobs},
+	}
+
+	for _, update := range updates {
+		if err := update.update(); err != nil {
+			log.Println("Updating", update.name, "of", character.CharacterName, err)
+			failed = append(failed, update.name)
+		}
+	}
+
+	evedb.Take(&character, character.ID)
+	return character, failed
+}
+
 func IndexController(c *gin.Context) {
 	maybe_user, exists := c.Get("user")
 	if exists {
-		esi := esi.NewESIClient(db.OpenEveDatabase(), maybe_user.(db.ESIUser))
-		esi.UpdateSystemCostIndices()
+		evedb := db.OpenEveDatabase()
+		syncErrors := make([]string, 0)
+
+		client := esi.NewESIClient(evedb, maybe_user.(db.ESIUser))
+		if err := client.UpdateSystemCostIndices(); err != nil {
+			log.Println("Updating system cost indices", err)
+			syncErrors = append(syncErrors, "system cost indices")
+		}
+
+		if err := client.UpdateAdjustedPrices(); err != nil {
+			log.Println("Updating adjusted prices", err)
+			syncErrors = append(syncErrors, "adjusted prices")
+		}
+
+		session := sessions.OpenSession(c)
+		available, _ := session.Get("available_users").([]db.ESIUser)
+
+		// Session keeps a snapshot, tokens and corporations may have changed since
+		characters := make([]db.ESIUser, 0, len(available))
+		for _, character := range available {
+			if evedb.Take(&character, character.ID).Error != nil {
+				continue
+			}
+
+			character, failed := syncCharacter(evedb, character)
+			for _, name := range failed {
+				syncErrors = append(syncErrors, name+" of "+character.CharacterName)
+			}
+
+			characters = append(characters, character)
+		}
+
+		session.Set("available_users", characters)
+		if current, exists := session.Get("current_user").(db.ESIUser); exists {
+			for _, character := range characters {
+				if character.ID == current.ID {
+					session.Set("current_user", character)
+				}
+			}
+		}
+		session.Save()
+
+		characterIDs := make([]uint64, 0, len(characters))
+		corporationIDs := make([]uint64, 0, len(characters))
+		for _, character := range characters {
+			characterIDs = append(characterIDs, character.ID)
+			corporationIDs = append(corporationIDs, character.CorporationId)
+		}
 
-		user, _ := esi.GetCharacterInfo(maybe_user.(db.ESIUser).ID)
-		log.Println(user)
+		layout.Render(c, "default/dashboard.tmpl", gin.H{
+			"jobs":       db.FindIndustryJobs(evedb, characterIDs, corporationIDs),
+			"syncErrors": syncErrors,
+		})
+		return
 	}
 
 	layout.Render(c, "default/login.tmpl", gin.H{})
@@ -16,6 +16,7 @@ import (
 
 	"github.com/mgibula/eve-industry/server/config"
 	"github.com/mgibula/eve-industry/server/db"
+	"github.com/mgibula/eve-industry/server/esi"
 	"github.com/mgibula/eve-industry/server/sessions"
 
 	jwks "github.com/MicahParks/keyfunc"
@@ -36,14 +37,17 @@ type callbackParams struct {
 
 var jwksKeys *jwks.JWKS
 
-func init() {
+// Fetched when routes are registered, so CLI mode does not need network access
+func loadJWKS() {
 	keys, err := jwks.Get("https://login.eveonline.com/oauth/jwks", jwks.Options{})
 	if err != nil {
 		log.Fatalf("Failed to get the JWKS from the given URL.\nError:%s", err.Error())
 	}
 
 	jwksKeys = keys
+}
 
+func init() {
 	jwt.TimeFunc = func() time.Time {
 		return time.Now().UTC().Add(time.Second * 20)
 	}
@@ -161,6 +165,13 @@ func ssoCallbackHandler(c *gin.Context) {
 	esiUser.AccessToken = responseData.AccessToken
 	esiUser.ValidUntil = expires
 
+	esiClient := esi.NewESIClient(manager, esiUser)
+	info, err := esiClient.GetCharacterInfo(characterId)
+	if err != nil {
+		log.Println("Error while fetching character info", err)
+	}
+	esiUser.CorporationId = uint64(info.CorporationID)
+
 	if result.RowsAffected > 0 {
 		log.Println("Updating ESI user")
 		manager.Save(&esiUser)
@@ -3,6 +3,8 @@ package sso
 import "github.com/gin-gonic/gin"
 
 func RegisterRoutes(c *gin.Engine) {
+	loadJWKS()
+
 	c.GET("/sso/redirect", ssoRedirectHandler)
 	c.GET("/sso/callback", ssoCallbackHandler)
 }
@@ -27,6 +27,7 @@ func ssoRedirectHandler(c *gin.Context) {
 		"esi-corporations.read_blueprints.v1",
 		"esi-industry.read_corporation_jobs.v1",
 		"esi-skills.read_skills.v1",
+		"esi-universe.read_structures.v1",
 	}
 
 	ssoState := fmt.Sprint(rand.Uint64())